@@ -3,17 +3,39 @@ package main
 import (
 	"GossamerDB/internal/config"
 	"GossamerDB/internal/gossip"
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/node"
 	"GossamerDB/internal/security"
+	"GossamerDB/pkg/model"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 var (
 	configFile = flag.String("config-file-path", "~/code/GossamerDB/config.yaml", "path of the config file")
 	nodeId     = flag.String("node-id", "", "id of the node")
+	capacity   = flag.Int("capacity", 1, "relative capacity of the node")
+)
+
+// buildVersion is overridden at build time with -ldflags "-X main.buildVersion=...".
+var buildVersion = "dev"
+
+const (
+	shutdownTimeout       = 5 * time.Second
+	membershipEventBuffer = 256
+	loadReportInterval    = 5 * time.Second
+	hintExpiryInterval    = time.Minute
 )
 
 func init() {
@@ -31,23 +53,66 @@ func main() {
 
 	// This is the entry point for the data node service.
 	// The main function will initialize and start the data node.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	startDataNode()
-	runGossip(ctx)
 	_, err := security.LoadMTLSConfig()
 	if err != nil {
 		panic(err)
 	}
+
+	ring, err := hashring.NewPlacement(
+		config.ConfigObj.Cluster.Placement,
+		hashring.SetVirtualNodes(config.ConfigObj.Cluster.VirtualNode),
+		hashring.SetReplicationFactor(config.ConfigObj.Cluster.TotalReplicas),
+		hashring.SetPlacementPolicy(placementDomain(config.ConfigObj.Cluster.ReplicaPlacement)),
+		hashring.SetLoadBound(config.ConfigObj.Cluster.LoadBound),
+	)
+	if err != nil {
+		log.Fatalf("failed to initialize placement: %v", err)
+	}
+	dataNode, err := node.NewDataNode(ring)
+	if err != nil {
+		log.Fatalf("failed to initialize data node: %v", err)
+	}
+	dataServer := node.NewServer(":"+strconv.Itoa(config.ConfigObj.Cluster.DataPort), dataNode)
+	go func() {
+		if err := dataServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("data server error: %v", err)
+		}
+	}()
+
+	runGossip(ctx, ring, dataNode)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := dataServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("data server shutdown error: %v", err)
+	}
 }
 
-func runGossip(ctx context.Context) {
+func runGossip(ctx context.Context, ring hashring.Placement, dataNode *node.DataNode) {
 	gossipEngine, err := gossip.NewEngine(config.ConfigObj.Gossip)
 	if err != nil {
 		log.Fatalf("failed to initialize gossip engine: %v", err)
 	}
 
+	gossipEngine.SetMetadata(map[string]string{
+		model.MetaHost:            advertiseHost(),
+		model.MetaDataPort:        strconv.Itoa(config.ConfigObj.Cluster.DataPort),
+		model.MetaCoordinatorPort: strconv.Itoa(config.ConfigObj.Cluster.CoordinatorPort),
+		model.MetaBuildVersion:    buildVersion,
+		model.MetaWireFormats:     "json",
+		model.MetaLoad:            "0",
+		model.MetaCapacity:        strconv.Itoa(*capacity),
+	})
+
+	dataNode.SetFailureDetector(gossipEngine.IsAlive)
+	go syncHashRing(ctx, gossipEngine, ring)
+	go reportLoad(ctx, gossipEngine, ring, dataNode)
+	go replayHints(ctx, gossipEngine, dataNode)
+	go dataNode.RunAntiEntropy(ctx)
 	go gossipEngine.Start(ctx)
 
 	// Start gossip HTTP server
@@ -58,7 +123,206 @@ func runGossip(ctx context.Context) {
 		}
 	}()
 
+	<-ctx.Done()
+	shutdownGossip(gossipEngine, gossipServer)
+}
+
+// shutdownGossip tells peers this node is leaving before the server goes away.
+func shutdownGossip(engine *gossip.Engine, server *gossip.Server) {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := engine.Leave(shutdownCtx); err != nil {
+		log.Printf("failed to leave gossip cluster: %v", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("gossip server shutdown error: %v", err)
+	}
+	engine.WaitStopped()
+}
+
+// syncHashRing keeps the hash ring in step with gossip membership: members are
+// placed on the ring when they join and taken off once they are dead or left.
+// Suspect members stay on the ring to avoid churn on a missed heartbeat, and
+// metadata updates replace the ring's copy of the member, reweighting it if
+// its advertised capacity changed. Updates of the load alone are left to
+// reportLoad, as they come from every member every interval.
+func syncHashRing(ctx context.Context, engine *gossip.Engine, ring hashring.Placement) {
+	events, cancel := engine.Subscribe(membershipEventBuffer)
+	defer cancel()
+
+	for _, member := range engine.Members() {
+		addRingNode(ring, member)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			switch ev.Type {
+			case gossip.EventJoined, gossip.EventAlive:
+				addRingNode(ring, ev.Member)
+			case gossip.EventMetadataUpdated:
+				if onlyLoadChanged(ring, ev.Member) {
+					continue
+				}
+				// Replace the member so its address, location and capacity
+				// match what it now advertises.
+				if err := ring.UpdateNode(ev.Member); err != nil && !errors.Is(err, hashring.ErrNodeNotFound) {
+					log.Printf("failed to update %s on hash ring: %v", ev.Member.ID, err)
+				}
+			case gossip.EventDead, gossip.EventLeft:
+				if err := ring.RemoveNode(ev.Member); err != nil && !errors.Is(err, hashring.ErrNodeNotFound) {
+					log.Printf("failed to remove %s from hash ring: %v", ev.Member.ID, err)
+				}
+			}
+		}
+	}
 }
+
+// replayHints hands hinted writes back to their owners when gossip reports
+// them alive again. Periodically it drops hints that got too old and retries
+// owners that are alive but still have hints, such as ones whose data server
+// was not up yet when gossip first saw them back.
+func replayHints(ctx context.Context, engine *gossip.Engine, dataNode *node.DataNode) {
+	events, cancel := engine.Subscribe(membershipEventBuffer)
+	defer cancel()
+
+	ticker := time.NewTicker(hintExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			dataNode.ExpireHints()
+			retryHints(ctx, engine, dataNode)
+		case ev := <-events:
+			if ev.Type != gossip.EventJoined && ev.Type != gossip.EventAlive {
+				continue
+			}
+			if base := ev.Member.DataURL(); base != "" {
+				go dataNode.ReplayHints(ctx, ev.Member.ID, base)
+			}
+		}
+	}
+}
+
+// retryHints starts replay to every alive owner that has hints waiting.
+func retryHints(ctx context.Context, engine *gossip.Engine, dataNode *node.DataNode) {
+	targets := dataNode.HintTargets()
+	if len(targets) == 0 {
+		return
+	}
+	for _, member := range engine.Members() {
+		if !slices.Contains(targets, member.ID) || !engine.IsAlive(member.ID) {
+			continue
+		}
+		if base := member.DataURL(); base != "" {
+			go dataNode.ReplayHints(ctx, member.ID, base)
+		}
+	}
+}
+
+// onlyLoadChanged reports whether member advertises the same address and
+// metadata as the ring's copy of it, apart from its load.
+func onlyLoadChanged(ring hashring.Placement, member model.Member) bool {
+	node, err := ring.GetNode(member.ID)
+	if err != nil {
+		return false
+	}
+	current, ok := node.(model.Member)
+	if !ok || current.URL != member.URL {
+		return false
+	}
+	return maps.Equal(withoutLoad(current.Metadata), withoutLoad(member.Metadata))
+}
+
+func withoutLoad(metadata map[string]string) map[string]string {
+	out := maps.Clone(metadata)
+	delete(out, model.MetaLoad)
+	return out
+}
+
+// reportLoad publishes how many requests this node served in each interval
+// to peers through gossip metadata. Every interval it also sets the load of
+// each member on the local ring to the last count the member reported, which
+// also clears the requests this node routed to it since, so bounded-load
+// lookups steer traffic away from busy nodes.
+func reportLoad(ctx context.Context, engine *gossip.Engine, ring hashring.Placement, dataNode *node.DataNode) {
+	ticker := time.NewTicker(loadReportInterval)
+	defer ticker.Stop()
+
+	last := dataNode.RequestCount()
+	published := int64(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count := dataNode.RequestCount()
+			load := int64(count - last)
+			last = count
+			// Unchanged loads are not republished, so idle clusters do not
+			// gossip a metadata update from every node every interval.
+			if load != published {
+				engine.SetMetadata(map[string]string{model.MetaLoad: strconv.FormatInt(load, 10)})
+				published = load
+			}
+			for _, member := range engine.Members() {
+				if member.ID != config.SelfID {
+					setRingLoad(ring, member.ID, int64(member.IntMetadata(model.MetaLoad, 0)))
+				}
+			}
+			setRingLoad(ring, config.SelfID, load)
+		}
+	}
+}
+
+// setRingLoad records a member's load on placements that balance by load.
+func setRingLoad(ring hashring.Placement, id string, load int64) {
+	lr, ok := ring.(interface{ SetLoad(string, int64) error })
+	if !ok {
+		return
+	}
+	if err := lr.SetLoad(id, load); err != nil && !errors.Is(err, hashring.ErrNodeNotFound) {
+		log.Printf("failed to set load of %s on hash ring: %v", id, err)
+	}
+}
+
+// advertiseHost returns the configured host for peers to reach this node at,
+// falling back to the hostname.
+func advertiseHost() string {
+	if config.ConfigObj.Cluster.AdvertiseHost != "" {
+		return config.ConfigObj.Cluster.AdvertiseHost
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("failed to get hostname to advertise: %v", err)
+	}
+	return host
+}
+
+func placementDomain(p config.ReplicaPlacement) hashring.FailureDomain {
+	switch p {
+	case config.ReplicaPlacementRack:
+		return hashring.DomainRack
+	case config.ReplicaPlacementZone:
+		return hashring.DomainZone
+	case config.ReplicaPlacementRegion:
+		return hashring.DomainRegion
+	default:
+		return hashring.DomainNone
+	}
+}
+
+func addRingNode(ring hashring.Placement, member model.Member) {
+	if err := ring.AddNode(member); err != nil && !errors.Is(err, hashring.ErrNodeExists) {
+		log.Printf("failed to add %s to hash ring: %v", member.ID, err)
+	}
+}
+
 func startDataNode() {
 	// Initialization and startup logic for the data node goes here.
 	// This could include setting up connections, loading configurations, etc.
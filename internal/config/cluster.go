@@ -1,6 +1,10 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+
+	"GossamerDB/internal/hashring"
+)
 
 type ClusterMode string
 
@@ -26,20 +30,67 @@ func (cm *ClusterMode) validate() error {
 	}
 }
 
+type ReplicaPlacement string
+
+const (
+	// ReplicaPlacementRing places replicas on the next distinct nodes on the ring.
+	ReplicaPlacementRing ReplicaPlacement = "ring"
+	// ReplicaPlacementRack spreads replicas across racks.
+	ReplicaPlacementRack ReplicaPlacement = "rack"
+	// ReplicaPlacementZone spreads replicas across availability zones.
+	ReplicaPlacementZone ReplicaPlacement = "zone"
+	// ReplicaPlacementRegion spreads replicas across regions.
+	ReplicaPlacementRegion ReplicaPlacement = "region"
+)
+
+func (rp ReplicaPlacement) String() string {
+	return string(rp)
+}
+
+func (rp *ReplicaPlacement) validate() error {
+	switch *rp {
+	case "", ReplicaPlacementRing, ReplicaPlacementRack, ReplicaPlacementZone, ReplicaPlacementRegion:
+		return nil
+	default:
+		return fmt.Errorf("invalid replica placement: %s", *rp)
+	}
+}
+
 type ClusterInfo struct {
-	Mode              ClusterMode `json:"mode" yaml:"mode"`                           // Mode of the cluster (e.g., "distributed", "standalone")
-	VirtualNode       int         `json:"virtualNode" yaml:"virtualNode"`             // number of virtual nodes
-	MaxNodesPerRegion int         `json:"maxNodesPerRegion" yaml:"maxNodesPerRegion"` // Maximum number of nodes allowed per region
-	TotalReplicas     int         `json:"totalReplicas" yaml:"totalReplicas"`         // Total number of replicas for data redundancy
-	ReadQuorum        int         `json:"readQuorum" yaml:"readQuorum"`               // Number of nodes required to read data
-	WriteQuorum       int         `json:"writeQuorum" yaml:"writeQuorum"`             // Number of nodes required to write data
-	CoordinatorPort   int         `json:"coordinatorPort" yaml:"coordinatorPort"`     // Port for the coordinator service
+	Mode              ClusterMode        `json:"mode" yaml:"mode"`                           // Mode of the cluster (e.g., "distributed", "standalone")
+	VirtualNode       int                `json:"virtualNode" yaml:"virtualNode"`             // number of virtual nodes
+	MaxNodesPerRegion int                `json:"maxNodesPerRegion" yaml:"maxNodesPerRegion"` // Maximum number of nodes allowed per region
+	TotalReplicas     int                `json:"totalReplicas" yaml:"totalReplicas"`         // Total number of replicas for data redundancy
+	ReadQuorum        int                `json:"readQuorum" yaml:"readQuorum"`               // Number of nodes required to read data
+	WriteQuorum       int                `json:"writeQuorum" yaml:"writeQuorum"`             // Number of nodes required to write data
+	CoordinatorPort   int                `json:"coordinatorPort" yaml:"coordinatorPort"`     // Port for the coordinator service
+	DataPort          int                `json:"dataPort" yaml:"dataPort"`                   // Port for the data node service
+	AdvertiseHost     string             `json:"advertiseHost" yaml:"advertiseHost"`         // Host other nodes reach this node at, defaults to the hostname
+	ReplicaPlacement  ReplicaPlacement   `json:"replicaPlacement" yaml:"replicaPlacement"`   // Failure domain replicas are spread across
+	Placement         hashring.Algorithm `json:"placement" yaml:"placement"`                 // Algorithm mapping keys to nodes
+	LoadBound         float64            `json:"loadBound" yaml:"loadBound"`                 // ε of bounded-load hashing, nodes take at most (1+ε)× their share; 0 disables it
+	RequestTimeoutMs  int                `json:"requestTimeoutMs" yaml:"requestTimeoutMs"`   // How long a coordinated read or write waits for its quorum
 }
 
 func (c *ClusterInfo) validate() error {
 	if err := c.Mode.validate(); err != nil {
 		return err
 	}
+	if err := c.ReplicaPlacement.validate(); err != nil {
+		return err
+	}
+	if err := c.Placement.Validate(); err != nil {
+		return err
+	}
+	if c.LoadBound < 0 {
+		return fmt.Errorf("loadBound must be >= 0")
+	}
+	if c.VirtualNode < 1 {
+		return fmt.Errorf("virtualNode must be >= 1")
+	}
+	if c.RequestTimeoutMs < 1 {
+		return fmt.Errorf("requestTimeoutMs must be >= 1")
+	}
 	if c.ReadQuorum < 1 || c.WriteQuorum < 1 || c.TotalReplicas < 1 {
 		return fmt.Errorf("all quorums must be >= 1")
 	}
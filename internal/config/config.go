@@ -9,6 +9,8 @@ import (
 	"strings"
 	"sync"
 
+	"GossamerDB/internal/hashring"
+
 	"gopkg.in/yaml.v2"
 )
 
@@ -55,6 +57,12 @@ type Config struct {
 	Monitoring MonitoringInfo `json:"monitoring" yaml:"monitoring"`
 	// Repair contains the configuration settings for repair operations, including anti-entropy intervals.
 	Repair RepairInfo `json:"repair" yaml:"repair"`
+	// Handoff contains the configuration settings for streaming token ranges
+	// between data nodes when ownership changes.
+	Handoff HandoffInfo `json:"handoff" yaml:"handoff"`
+	// HintedHandoff contains the configuration settings for hints kept by
+	// stand-in replicas for writes meant for nodes that are down.
+	HintedHandoff HintedHandoffInfo `json:"hintedHandoff" yaml:"hintedHandoff"`
 }
 
 var (
@@ -98,21 +106,29 @@ func initializeDefaultConfig() *Config {
 	return &Config{
 		Cluster: ClusterInfo{
 			Mode:              ClusterModeK8s,
+			VirtualNode:       3,
 			MaxNodesPerRegion: 10,
 			TotalReplicas:     3,
 			ReadQuorum:        2,
 			WriteQuorum:       2,
 			CoordinatorPort:   8080,
+			DataPort:          8081,
+			ReplicaPlacement:  ReplicaPlacementZone,
+			Placement:         hashring.AlgorithmConsistent,
+			RequestTimeoutMs:  2000,
 		},
 		Gossip: GossipInfo{
-			InitiationStrategy: GossipStrategyRumorMongering,
-			SpreadStrategy:     GossipSpreadStrategyPush,
-			Fanout:             3,
-			IntervalMs:         1000,
-			NodeInfoPerMsg:     10,
+			InitiationStrategy:   GossipStrategyRumorMongering,
+			SpreadStrategy:       GossipSpreadStrategyPush,
+			Fanout:               3,
+			IntervalMs:           1000,
+			NodeInfoPerMsg:       10,
+			DeadNodeTimeoutMs:    30000,
+			CrossRegionFanout:    1,
+			WANDeadNodeTimeoutMs: 90000,
 		},
 		MerkleTree: MerkleTreeInfo{
-			BucketSize: 100,
+			Depth: 10,
 		},
 		VectorClock: VectorClockInfo{
 			ConflictResolution: VectorClockConflictResolutionLastWriteWins,
@@ -137,6 +153,20 @@ func initializeDefaultConfig() *Config {
 		Repair: RepairInfo{
 			Enabled:                      true,
 			AntiEntropyIntervalInSeconds: 1800,
+			MaxKeysPerRun:                100000,
+			MaxBytesPerRun:               64 << 20,
+			ReadRepairMode:               ReadRepairBackground,
+			ReadRepairProbability:        1,
+		},
+		Handoff: HandoffInfo{
+			BatchSize:             500,
+			MaxBytesPerSecond:     10 << 20,
+			DualWriteLeaseSeconds: 60,
+		},
+		HintedHandoff: HintedHandoffInfo{
+			MaxHintAgeSeconds:   3 * 60 * 60,
+			MaxHintsPerNode:     10000,
+			ReplayRatePerSecond: 100,
 		},
 	}
 }
@@ -152,6 +182,21 @@ func (c *Config) Validate() error {
 	if err := c.VectorClock.ConflictResolution.Validate(); err != nil {
 		return fmt.Errorf("vectorClock.conflictResolution: %w", err)
 	}
+	if err := c.Repair.validate(); err != nil {
+		return fmt.Errorf("repair: %w", err)
+	}
+	if c.Repair.Enabled && !c.Cluster.Placement.HasTokenRanges() {
+		return fmt.Errorf("repair: anti-entropy needs token ranges, which %s placement does not have; use consistent placement or disable repair", c.Cluster.Placement)
+	}
+	if err := c.MerkleTree.validate(); err != nil {
+		return fmt.Errorf("merkleTree: %w", err)
+	}
+	if err := c.Handoff.validate(); err != nil {
+		return fmt.Errorf("handoff: %w", err)
+	}
+	if err := c.HintedHandoff.validate(); err != nil {
+		return fmt.Errorf("hintedHandoff: %w", err)
+	}
 
 	return nil
 }
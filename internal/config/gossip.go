@@ -52,12 +52,18 @@ func (gss *GossipSpreadStrategy) validate() error {
 }
 
 type GossipInfo struct {
-	InitiationStrategy GossipStrategy       `json:"initiationStrategy" yaml:"initiationStrategy"` // Strategy for initiating gossip communication
-	SpreadStrategy     GossipSpreadStrategy `json:"spreadStrategy" yaml:"spreadStrategy"`         // Strategy for spreading gossip messages
-	Fanout             int                  `json:"fanout" yaml:"fanout"`                         // Number of nodes to which gossip messages are sent
-	IntervalMs         int                  `json:"intervalMs" yaml:"intervalMs"`                 // Interval for gossip message sending in milliseconds
-	NodeInfoPerMsg     int                  `json:"nodeInfoPerMsg" yaml:"nodeInfoPerMsg"`         // node info for each gossip message
-	Port               string               `json:"port" yaml:"port"`                             // posr on which we will run the gossip protocol
+	InitiationStrategy   GossipStrategy       `json:"initiationStrategy" yaml:"initiationStrategy"`     // Strategy for initiating gossip communication
+	SpreadStrategy       GossipSpreadStrategy `json:"spreadStrategy" yaml:"spreadStrategy"`             // Strategy for spreading gossip messages
+	Fanout               int                  `json:"fanout" yaml:"fanout"`                             // Number of nodes to which gossip messages are sent
+	IntervalMs           int                  `json:"intervalMs" yaml:"intervalMs"`                     // Interval for gossip message sending in milliseconds
+	NodeInfoPerMsg       int                  `json:"nodeInfoPerMsg" yaml:"nodeInfoPerMsg"`             // node info for each gossip message
+	Port                 string               `json:"port" yaml:"port"`                                 // posr on which we will run the gossip protocol
+	DeadNodeTimeoutMs    int                  `json:"deadNodeTimeoutMs" yaml:"deadNodeTimeoutMs"`       // Time without a heartbeat after which a member is expired
+	Region               string               `json:"region" yaml:"region"`                             // Region this node runs in
+	Zone                 string               `json:"zone" yaml:"zone"`                                 // Availability zone this node runs in
+	Rack                 string               `json:"rack" yaml:"rack"`                                 // Rack this node runs in
+	CrossRegionFanout    int                  `json:"crossRegionFanout" yaml:"crossRegionFanout"`       // Number of peers in other regions gossiped to each round
+	WANDeadNodeTimeoutMs int                  `json:"wanDeadNodeTimeoutMs" yaml:"wanDeadNodeTimeoutMs"` // Dead node timeout for members in other regions
 }
 
 func (c *GossipInfo) validate() error {
@@ -76,5 +82,14 @@ func (c *GossipInfo) validate() error {
 	if c.NodeInfoPerMsg < 5 {
 		return errors.New("NodeInfoPerMsg must be >= 5")
 	}
+	if c.DeadNodeTimeoutMs <= c.IntervalMs {
+		return errors.New("DeadNodeTimeoutMs must be greater than IntervalMs")
+	}
+	if c.CrossRegionFanout < 0 || c.CrossRegionFanout > c.Fanout {
+		return errors.New("CrossRegionFanout must be between 0 and fanout")
+	}
+	if c.WANDeadNodeTimeoutMs < c.DeadNodeTimeoutMs {
+		return errors.New("WANDeadNodeTimeoutMs must be >= DeadNodeTimeoutMs")
+	}
 	return nil
 }
@@ -0,0 +1,22 @@
+package config
+
+import "errors"
+
+type HandoffInfo struct {
+	BatchSize             int   `json:"batchSize" yaml:"batchSize"`                         // Number of keys sent per range handoff batch
+	MaxBytesPerSecond     int64 `json:"maxBytesPerSecond" yaml:"maxBytesPerSecond"`         // Throttle for incoming range handoffs, 0 disables it
+	DualWriteLeaseSeconds int   `json:"dualWriteLeaseSeconds" yaml:"dualWriteLeaseSeconds"` // How long a source mirrors writes to a new owner that stopped streaming or renewing
+}
+
+func (h *HandoffInfo) validate() error {
+	if h.BatchSize < 1 {
+		return errors.New("batchSize must be >= 1")
+	}
+	if h.MaxBytesPerSecond < 0 {
+		return errors.New("maxBytesPerSecond must be >= 0")
+	}
+	if h.DualWriteLeaseSeconds < 1 {
+		return errors.New("dualWriteLeaseSeconds must be >= 1")
+	}
+	return nil
+}
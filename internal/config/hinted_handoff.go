@@ -0,0 +1,22 @@
+package config
+
+import "errors"
+
+type HintedHandoffInfo struct {
+	MaxHintAgeSeconds   int `json:"maxHintAgeSeconds" yaml:"maxHintAgeSeconds"`     // Hints older than this are dropped instead of replayed
+	MaxHintsPerNode     int `json:"maxHintsPerNode" yaml:"maxHintsPerNode"`         // Hints kept per down node; the oldest are dropped beyond it
+	ReplayRatePerSecond int `json:"replayRatePerSecond" yaml:"replayRatePerSecond"` // Hints replayed per second to each recovered node
+}
+
+func (h *HintedHandoffInfo) validate() error {
+	if h.MaxHintAgeSeconds < 1 {
+		return errors.New("maxHintAgeSeconds must be >= 1")
+	}
+	if h.MaxHintsPerNode < 1 {
+		return errors.New("maxHintsPerNode must be >= 1")
+	}
+	if h.ReplayRatePerSecond < 1 {
+		return errors.New("replayRatePerSecond must be >= 1")
+	}
+	return nil
+}
@@ -1,5 +1,14 @@
 package config
 
+import "errors"
+
 type MerkleTreeInfo struct {
-	BucketSize int `json:"bucketSize" yaml:"bucketSize"` // Size of the bucket in the Merkle tree
+	Depth int `json:"depth" yaml:"depth"` // Levels below the root; the hash space is split into 2^depth leaves
+}
+
+func (m *MerkleTreeInfo) validate() error {
+	if m.Depth < 0 || m.Depth > 24 {
+		return errors.New("depth must be between 0 and 24")
+	}
+	return nil
 }
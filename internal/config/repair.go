@@ -1,6 +1,59 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ReadRepairMode decides whether a read waits for stale replicas to be
+// repaired before answering. Empty means background.
+type ReadRepairMode string
+
+const (
+	// ReadRepairBlocking repairs stale replicas before the read returns, so a
+	// following read at the same quorum sees the repaired value.
+	ReadRepairBlocking ReadRepairMode = "blocking"
+	// ReadRepairBackground answers first and repairs stale replicas afterwards.
+	ReadRepairBackground ReadRepairMode = "background"
+)
+
+func (m ReadRepairMode) String() string {
+	return string(m)
+}
+
+func (m *ReadRepairMode) validate() error {
+	switch *m {
+	case "", ReadRepairBlocking, ReadRepairBackground:
+		return nil
+	default:
+		return fmt.Errorf("invalid read repair mode: %s", *m)
+	}
+}
+
 type RepairInfo struct {
-	Enabled                      bool `json:"enabled" yaml:"enabled"`                                           // Enable or disable repair operations
-	AntiEntropyIntervalInSeconds int  `json:"antiEntropyIntervalInSeconds" yaml:"antiEntropyIntervalInSeconds"` // Interval for anti-entropy operations in seconds
+	Enabled                      bool           `json:"enabled" yaml:"enabled"`                                           // Enable or disable repair operations
+	AntiEntropyIntervalInSeconds int            `json:"antiEntropyIntervalInSeconds" yaml:"antiEntropyIntervalInSeconds"` // Interval for anti-entropy operations in seconds
+	MaxKeysPerRun                int            `json:"maxKeysPerRun" yaml:"maxKeysPerRun"`                               // Keys compared per anti-entropy run, bounding its CPU use; 0 means no limit
+	MaxBytesPerRun               int64          `json:"maxBytesPerRun" yaml:"maxBytesPerRun"`                             // Bytes exchanged with peers per anti-entropy run; 0 means no limit
+	ReadRepairMode               ReadRepairMode `json:"readRepairMode" yaml:"readRepairMode"`                             // Whether reads wait for stale replicas to be repaired
+	ReadRepairProbability        float64        `json:"readRepairProbability" yaml:"readRepairProbability"`               // Share of divergent reads that repair replicas, between 0 and 1
+}
+
+func (r *RepairInfo) validate() error {
+	if r.Enabled && r.AntiEntropyIntervalInSeconds < 1 {
+		return errors.New("antiEntropyIntervalInSeconds must be >= 1")
+	}
+	if r.MaxKeysPerRun < 0 {
+		return errors.New("maxKeysPerRun must be >= 0")
+	}
+	if r.MaxBytesPerRun < 0 {
+		return errors.New("maxBytesPerRun must be >= 0")
+	}
+	if err := r.ReadRepairMode.validate(); err != nil {
+		return err
+	}
+	if r.ReadRepairProbability < 0 || r.ReadRepairProbability > 1 {
+		return errors.New("readRepairProbability must be between 0 and 1")
+	}
+	return nil
 }
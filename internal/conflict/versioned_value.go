@@ -1,6 +1,78 @@
 package conflict
 
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+)
+
 type VersionedValue struct {
-	Value []byte
-	Clock VectorClock
+	Value []byte      `json:"value"`
+	Clock VectorClock `json:"clock"`
+}
+
+// IsTombstone reports whether v records a delete rather than a value.
+func (v VersionedValue) IsTombstone() bool {
+	return v.Value == nil
+}
+
+// Markers distinguishing a tombstone, a version with a nil Value, from a
+// version holding an empty value.
+const (
+	canonicalTombstone byte = 0
+	canonicalValue     byte = 1
+)
+
+// AppendCanonical appends a byte encoding of the clock that depends only on
+// its entries: node IDs in sorted order, each length-prefixed and followed by
+// its counter. Zero counters are skipped, as they mean the same as absent ones.
+func (vc VectorClock) AppendCanonical(b []byte) []byte {
+	ids := make([]string, 0, len(vc))
+	for id, c := range vc {
+		if c != 0 {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	b = binary.AppendUvarint(b, uint64(len(ids)))
+	for _, id := range ids {
+		b = binary.AppendUvarint(b, uint64(len(id)))
+		b = append(b, id...)
+		b = binary.AppendVarint(b, int64(vc[id]))
+	}
+	return b
+}
+
+// AppendCanonical appends a byte encoding of the version: a tombstone or value
+// marker, the length-prefixed value, then the canonical clock.
+func (v VersionedValue) AppendCanonical(b []byte) []byte {
+	if v.Value == nil {
+		b = append(b, canonicalTombstone)
+	} else {
+		b = append(b, canonicalValue)
+		b = binary.AppendUvarint(b, uint64(len(v.Value)))
+		b = append(b, v.Value...)
+	}
+	return v.Clock.AppendCanonical(b)
+}
+
+// CanonicalEncoding encodes every sibling version of a key so that two
+// replicas holding the same versions, in any order, produce the same bytes,
+// and replicas differing in any value, clock or tombstone do not. Versions are
+// encoded one by one and sorted bytewise behind a count.
+func CanonicalEncoding(versions []VersionedValue) []byte {
+	encoded := make([][]byte, len(versions))
+	size := binary.MaxVarintLen64
+	for i, v := range versions {
+		encoded[i] = v.AppendCanonical(nil)
+		size += len(encoded[i])
+	}
+	slices.SortFunc(encoded, bytes.Compare)
+
+	b := make([]byte, 0, size)
+	b = binary.AppendUvarint(b, uint64(len(encoded)))
+	for _, e := range encoded {
+		b = append(b, e...)
+	}
+	return b
 }
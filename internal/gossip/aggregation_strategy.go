@@ -9,15 +9,16 @@ import (
 
 type AggregationStrategy struct{}
 
+// GenerateMessage sends a single live entry, keyed by the member it describes
+// so receivers never mistake the summary for a member of its own.
 func (a *AggregationStrategy) GenerateMessage(state model.NodeHealthInfo) model.GossipMessage {
-	healthy := time.Now()
-	for _, val := range state {
-		if !val.IsZero() {
-			healthy = val
+	summary := make(model.NodeHealthInfo, 1)
+	for id, val := range state {
+		if !val.Heartbeat.IsZero() {
+			summary[id] = val
 			break
 		}
 	}
-	summary := model.NodeHealthInfo{"healthy": healthy}
 	log.Printf("[STRATEGY] Aggregation sending summary gossip: %v", summary)
 	return model.GossipMessage{
 		SenderID:   config.SelfID,
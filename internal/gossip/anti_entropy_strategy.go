@@ -18,6 +18,21 @@ func (a *AntiEntropyStrategy) GenerateMessage(state model.NodeHealthInfo) model.
 	}
 }
 
+// GenerateDigest summarises state as entry versions; the peer answers with only
+// the entries that differ.
+func (a *AntiEntropyStrategy) GenerateDigest(state model.NodeHealthInfo) model.GossipMessage {
+	digest := make(model.NodeDigest, len(state))
+	for id, entry := range state {
+		digest[id] = entry.Version
+	}
+	log.Printf("[STRATEGY] Anti-Entropy generating digest with %d nodes", len(digest))
+	return model.GossipMessage{
+		SenderID:  config.SelfID,
+		Timestamp: time.Now(),
+		Digest:    digest,
+	}
+}
+
 func (a *AntiEntropyStrategy) Merge(local model.NodeHealthInfo, incoming model.GossipMessage) model.NodeHealthInfo {
 	return incoming.NodeHealth // In real system might be a merge but simple override appropriate here
 }
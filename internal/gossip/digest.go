@@ -0,0 +1,123 @@
+package gossip
+
+import (
+	"GossamerDB/pkg/model"
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// Delta compares a peer's digest with the local view. It returns the entries
+// the peer lacks or holds stale copies of, and the node IDs for which the peer
+// holds newer entries than we do. The delta is capped at NodeInfoPerMsg; the
+// remainder goes out in later rounds.
+func (e *Engine) Delta(digest model.NodeDigest) (model.NodeHealthInfo, []string) {
+	e.nodeHealthMu.Lock()
+	defer e.nodeHealthMu.Unlock()
+
+	newer := make([]string, 0)
+	for id, entry := range e.nodeHealth {
+		if entry.Version > digest[id] {
+			newer = append(newer, id)
+		}
+	}
+	request := make([]string, 0)
+	for id, version := range digest {
+		if _, gone := e.left[id]; gone {
+			continue
+		}
+		if version > e.nodeHealth[id].Version {
+			request = append(request, id)
+		}
+	}
+	slices.Sort(request)
+
+	delta := make(model.NodeHealthInfo)
+	for _, id := range e.nextChunk(newer) {
+		delta[id] = e.nodeHealth[id]
+	}
+	return delta, request
+}
+
+// Entries returns the local entries for ids, capped at NodeInfoPerMsg.
+func (e *Engine) Entries(ids []string) model.NodeHealthInfo {
+	e.nodeHealthMu.Lock()
+	defer e.nodeHealthMu.Unlock()
+
+	known := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := e.nodeHealth[id]; ok {
+			known = append(known, id)
+		}
+	}
+	entries := make(model.NodeHealthInfo)
+	for _, id := range e.nextChunk(known) {
+		entries[id] = e.nodeHealth[id]
+	}
+	return entries
+}
+
+// nextChunk picks at most NodeInfoPerMsg ids, starting from a cursor that moves
+// forward every call so that every id is eventually sent. Callers must hold
+// nodeHealthMu.
+func (e *Engine) nextChunk(ids []string) []string {
+	if len(ids) <= e.cfg.NodeInfoPerMsg {
+		return ids
+	}
+	slices.Sort(ids)
+	start := e.deltaCursor % len(ids)
+	e.deltaCursor += e.cfg.NodeInfoPerMsg
+	chunk := make([]string, 0, e.cfg.NodeInfoPerMsg)
+	for i := 0; i < e.cfg.NodeInfoPerMsg; i++ {
+		chunk = append(chunk, ids[(start+i)%len(ids)])
+	}
+	return chunk
+}
+
+// exchangeDigest runs both phases of a digest exchange with one peer: it sends
+// our digest and applies the delta that comes back, then pushes the entries
+// the peer asked for.
+func (e *Engine) exchangeDigest(url string, msg model.GossipMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal gossip digest: %v", err)
+		return
+	}
+	resp, err := http.Post(url+"/gossip/digest", "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		log.Printf("[ERROR] Failed sending gossip digest to %s: %v", url, err)
+		return
+	}
+	var reply model.DigestReply
+	err = json.NewDecoder(resp.Body).Decode(&reply)
+	resp.Body.Close()
+	if err != nil {
+		log.Printf("[ERROR] Failed decoding digest reply from %s: %v", url, err)
+		return
+	}
+	e.UpdateNodeHealth(reply.Delta)
+	log.Printf("[DIGEST] %s sent %d entries, requested %d", url, len(reply.Delta), len(reply.Request))
+
+	if len(reply.Request) == 0 {
+		return
+	}
+	push := model.GossipMessage{
+		SenderID:   msg.SenderID,
+		Timestamp:  msg.Timestamp,
+		NodeHealth: e.Entries(reply.Request),
+	}
+	e.stamp(&push)
+	payload, err = json.Marshal(push)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal gossip delta: %v", err)
+		return
+	}
+	resp, err = http.Post(url+"/gossip", "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		log.Printf("[ERROR] Failed sending gossip delta to %s: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+}
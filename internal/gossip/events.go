@@ -0,0 +1,104 @@
+package gossip
+
+import (
+	"GossamerDB/pkg/model"
+	"log"
+	"maps"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventJoined is emitted when a member is seen for the first time.
+	EventJoined EventType = "joined"
+	// EventSuspect is emitted when a member misses heartbeats for half its dead node timeout.
+	EventSuspect EventType = "suspect"
+	// EventAlive is emitted when a suspect member heartbeats again.
+	EventAlive EventType = "alive"
+	// EventDead is emitted when a member is expired for missing heartbeats.
+	EventDead EventType = "dead"
+	// EventLeft is emitted when a member leaves the cluster gracefully.
+	EventLeft EventType = "left"
+	// EventMetadataUpdated is emitted when a member advertises new metadata.
+	EventMetadataUpdated EventType = "metadata-updated"
+)
+
+// MembershipEvent describes one change to the membership view.
+type MembershipEvent struct {
+	Type   EventType
+	Member model.Member
+	Time   time.Time
+}
+
+// subscribers fans membership events out to every subscriber without ever
+// blocking the engine: events for a subscriber whose buffer is full are dropped.
+type subscribers struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]chan MembershipEvent
+}
+
+// Subscribe returns a channel receiving membership events, buffered up to
+// buffer events, and a function that cancels the subscription and closes the
+// channel. Slow subscribers miss events instead of stalling gossip.
+func (e *Engine) Subscribe(buffer int) (<-chan MembershipEvent, func()) {
+	e.events.mu.Lock()
+	defer e.events.mu.Unlock()
+
+	if e.events.subs == nil {
+		e.events.subs = make(map[int]chan MembershipEvent)
+	}
+	id := e.events.nextID
+	e.events.nextID++
+	ch := make(chan MembershipEvent, max(buffer, 1))
+	e.events.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.events.mu.Lock()
+			defer e.events.mu.Unlock()
+			delete(e.events.subs, id)
+			close(ch)
+		})
+	}
+}
+
+// publish delivers events to every subscriber. It must be called without
+// holding nodeHealthMu or peersMu.
+func (e *Engine) publish(events ...MembershipEvent) {
+	if len(events) == 0 {
+		return
+	}
+	e.events.mu.Lock()
+	defer e.events.mu.Unlock()
+
+	for _, ev := range events {
+		for id, ch := range e.events.subs {
+			select {
+			case ch <- ev:
+			default:
+				log.Printf("[GOSSIP] Subscriber %d is full, dropping %s event for %s", id, ev.Type, ev.Member.ID)
+			}
+		}
+	}
+}
+
+// newEvent builds an event for nodeID from its health entry. Callers must hold
+// nodeHealthMu.
+func (e *Engine) newEvent(t EventType, nodeID string, entry model.NodeHealth, status model.MemberStatus) MembershipEvent {
+	return MembershipEvent{
+		Type: t,
+		Member: model.Member{
+			ID:              nodeID,
+			Status:          status,
+			Heartbeat:       entry.Heartbeat,
+			Version:         entry.Version,
+			Metadata:        maps.Clone(entry.Metadata),
+			MetadataVersion: entry.MetadataVersion,
+		},
+		Time: time.Now(),
+	}
+}
@@ -3,22 +3,46 @@ package gossip
 import (
 	"GossamerDB/internal/config"
 	"GossamerDB/pkg/model"
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"maps"
 	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
+// peer is a gossip member this node can send messages to.
+type peer struct {
+	URL    string
+	Region string
+	Zone   string
+}
+
 type Engine struct {
 	cfg          config.GossipInfo
 	initiation   GossipStrategy
 	spread       SpreadStrategy
+	incarnation  uint64
+	version      uint64 // version of this node's own health entry
+	metadata     map[string]string
+	metaVersion  uint64
+	deltaCursor  int // rotates which entries an oversized delta carries
 	nodeHealth   model.NodeHealthInfo
-	peers        []string
+	status       map[string]model.MemberStatus // nodeID → liveness, for entries in nodeHealth
+	peers        map[string]peer               // nodeID → gossip endpoint and topology
+	regions      map[string]string             // nodeID → region, learned from joins and gossip
+	left         map[string]uint64             // nodeID → incarnation the node left with
+	incarnations map[string]uint64             // nodeID → newest incarnation the node gossiped with
+	leftAt       map[string]time.Time          // nodeID → time the leave was observed
 	nodeHealthMu sync.RWMutex
-	configLock   sync.RWMutex
+	peersMu      sync.RWMutex
+	events       subscribers
 	stopCh       chan struct{}
 	stoppedCh    chan struct{}
 }
@@ -27,13 +51,25 @@ func NewEngine(cfg config.GossipInfo) (*Engine, error) {
 	initiation := GetGossipStrategy(cfg.InitiationStrategy)
 	spread := GetSpreadStrategy(cfg.SpreadStrategy)
 	return &Engine{
-		cfg:          cfg,
-		initiation:   initiation,
-		spread:       spread,
-		nodeHealth:   make(map[string]time.Time),
-		peers:        make([]string, 0),
+		cfg:        cfg,
+		initiation: initiation,
+		spread:     spread,
+		// Start time keeps the incarnation monotonic across restarts.
+		incarnation: uint64(time.Now().UnixNano()),
+		nodeHealth:  make(model.NodeHealthInfo),
+		status:      make(map[string]model.MemberStatus),
+		metadata: map[string]string{
+			model.MetaRegion: cfg.Region,
+			model.MetaZone:   cfg.Zone,
+			model.MetaRack:   cfg.Rack,
+		},
+		peers:        make(map[string]peer),
+		regions:      make(map[string]string),
+		left:         make(map[string]uint64),
+		incarnations: make(map[string]uint64),
+		leftAt:       make(map[string]time.Time),
 		nodeHealthMu: sync.RWMutex{},
-		configLock:   sync.RWMutex{},
+		peersMu:      sync.RWMutex{},
 		stopCh:       make(chan struct{}),
 		stoppedCh:    make(chan struct{}),
 	}, nil
@@ -52,6 +88,7 @@ func (e *Engine) Start(ctx context.Context) {
 			close(e.stoppedCh)
 			return
 		case <-ticker.C:
+			e.expireMembers()
 			e.doGossip()
 		}
 	}
@@ -59,9 +96,7 @@ func (e *Engine) Start(ctx context.Context) {
 
 // doGossip composes gossip messages and spreads to peers
 func (e *Engine) doGossip() {
-	state := e.GetNodeHealth()
-
-	msg := e.initiation.GenerateMessage(state)
+	e.heartbeat()
 
 	peers := e.GetRandomPeers()
 
@@ -69,20 +104,133 @@ func (e *Engine) doGossip() {
 		log.Println("[GOSSIP] No peers available to gossip")
 		return
 	}
+
+	if ds, ok := e.initiation.(DigestStrategy); ok {
+		msg := ds.GenerateDigest(e.snapshot())
+		e.stamp(&msg)
+		log.Printf("[GOSSIP] Exchanging digests with %d peers", len(peers))
+		for _, peer := range peers {
+			go e.exchangeDigest(peer, msg)
+		}
+		return
+	}
+
+	msg := e.initiation.GenerateMessage(e.GetNodeHealth())
+	e.stamp(&msg)
 	log.Printf("[GOSSIP] Gossiping to %d peers", len(peers))
 	e.spread.Spread(msg, peers)
 }
 
+// stamp fills in the sender fields every outgoing message carries.
+func (e *Engine) stamp(msg *model.GossipMessage) {
+	msg.Incarnation = e.incarnation
+	msg.Region = e.cfg.Region
+	msg.Zone = e.cfg.Zone
+	msg.Left = e.getLeft()
+}
+
+// heartbeat refreshes this node's own health entry under a new version.
+func (e *Engine) heartbeat() {
+	e.nodeHealthMu.Lock()
+	if e.version < e.incarnation {
+		// Start from the incarnation so versions keep growing across restarts.
+		e.version = e.incarnation
+	}
+	if e.metaVersion < e.incarnation {
+		e.metaVersion = e.incarnation
+	}
+	e.version++
+	previous, known := e.nodeHealth[config.SelfID]
+	entry := model.NodeHealth{
+		Heartbeat:       time.Now(),
+		Version:         e.version,
+		Metadata:        e.metadata,
+		MetadataVersion: e.metaVersion,
+	}
+	e.nodeHealth[config.SelfID] = entry
+	e.status[config.SelfID] = model.MemberAlive
+
+	var events []MembershipEvent
+	if !known {
+		events = append(events, e.newEvent(EventJoined, config.SelfID, entry, model.MemberAlive))
+	} else if previous.MetadataVersion < entry.MetadataVersion {
+		events = append(events, e.newEvent(EventMetadataUpdated, config.SelfID, entry, model.MemberAlive))
+	}
+	e.nodeHealthMu.Unlock()
+	e.publish(events...)
+}
+
+// SetMetadata merges values into the metadata this node advertises. The next
+// heartbeat carries them under a new metadata version.
+func (e *Engine) SetMetadata(values map[string]string) {
+	e.nodeHealthMu.Lock()
+	defer e.nodeHealthMu.Unlock()
+
+	// Entries share the map with copies handed out earlier, so never mutate it
+	// in place.
+	updated := maps.Clone(e.metadata)
+	maps.Copy(updated, values)
+	if maps.Equal(updated, e.metadata) {
+		return
+	}
+	e.metadata = updated
+	if e.metaVersion < e.incarnation {
+		e.metaVersion = e.incarnation
+	}
+	e.metaVersion++
+}
+
+// Members returns every live member known to this node, sorted by ID.
+func (e *Engine) Members() []model.Member {
+	e.nodeHealthMu.RLock()
+	state := maps.Clone(e.nodeHealth)
+	status := maps.Clone(e.status)
+	e.nodeHealthMu.RUnlock()
+
+	e.peersMu.RLock()
+	defer e.peersMu.RUnlock()
+
+	members := make([]model.Member, 0, len(state))
+	for id, entry := range state {
+		members = append(members, model.Member{
+			ID:              id,
+			Status:          status[id],
+			URL:             e.peers[id].URL,
+			Heartbeat:       entry.Heartbeat,
+			Version:         entry.Version,
+			Metadata:        maps.Clone(entry.Metadata),
+			MetadataVersion: entry.MetadataVersion,
+		})
+	}
+	slices.SortFunc(members, func(a, b model.Member) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return members
+}
+
+// IsAlive reports whether the failure detector considers a member up. Suspect,
+// dead, departed and unknown members are all down.
+func (e *Engine) IsAlive(nodeID string) bool {
+	e.nodeHealthMu.RLock()
+	defer e.nodeHealthMu.RUnlock()
+	return e.status[nodeID] == model.MemberAlive
+}
+
 // Stop waits for engine to stop
 func (e *Engine) WaitStopped() {
 	<-e.stoppedCh
 }
 
+// Incarnation returns the incarnation this engine advertises for the local node.
+func (e *Engine) Incarnation() uint64 {
+	return e.incarnation
+}
+
 func (e *Engine) GetNodeHealth() model.NodeHealthInfo {
 	e.nodeHealthMu.RLock()
 	defer e.nodeHealthMu.RUnlock()
 
-	copy := make(map[string]time.Time)
+	copy := make(model.NodeHealthInfo)
 	i := 0
 	for k, v := range e.nodeHealth {
 		copy[k] = v
@@ -94,30 +242,326 @@ func (e *Engine) GetNodeHealth() model.NodeHealthInfo {
 	return copy
 }
 
+// snapshot returns a copy of every health entry, regardless of NodeInfoPerMsg.
+func (e *Engine) snapshot() model.NodeHealthInfo {
+	e.nodeHealthMu.RLock()
+	defer e.nodeHealthMu.RUnlock()
+	return maps.Clone(e.nodeHealth)
+}
+
+// UpdateNodeHealth merges entries with a higher version into the local view,
+// ignoring nodes that have left and heartbeats already older than the dead
+// node timeout.
 func (e *Engine) UpdateNodeHealth(newHealth model.NodeHealthInfo) {
+	regions := e.getRegions()
+
 	e.nodeHealthMu.Lock()
-	defer e.nodeHealthMu.Unlock()
-	maps.Copy(e.nodeHealth, newHealth)
+	learned := make(map[string]string)
+	events := make([]MembershipEvent, 0)
+	now := time.Now()
+	for id, entry := range newHealth {
+		if id == config.SelfID {
+			continue
+		}
+		if _, gone := e.left[id]; gone || entry.Heartbeat.Before(now.Add(-e.deadNodeTimeout(regions, id))) {
+			continue
+		}
+		current, known := e.nodeHealth[id]
+		if entry.Version <= current.Version {
+			continue
+		}
+		if !known && entry.MetadataVersion == 0 {
+			// Every member advertises its metadata from its first heartbeat;
+			// an unknown ID without any is not a member.
+			continue
+		}
+		if entry.MetadataVersion < current.MetadataVersion {
+			entry.Metadata, entry.MetadataVersion = current.Metadata, current.MetadataVersion
+		}
+		e.nodeHealth[id] = entry
+		if region, ok := entry.Metadata[model.MetaRegion]; ok {
+			learned[id] = region
+		}
+
+		switch {
+		case !known:
+			events = append(events, e.newEvent(EventJoined, id, entry, model.MemberAlive))
+		case e.status[id] == model.MemberSuspect:
+			events = append(events, e.newEvent(EventAlive, id, entry, model.MemberAlive))
+		}
+		if known && entry.MetadataVersion > current.MetadataVersion {
+			events = append(events, e.newEvent(EventMetadataUpdated, id, entry, model.MemberAlive))
+		}
+		e.status[id] = model.MemberAlive
+	}
+	e.nodeHealthMu.Unlock()
+	e.publish(events...)
+
+	if len(learned) > 0 {
+		e.peersMu.Lock()
+		maps.Copy(e.regions, learned)
+		e.peersMu.Unlock()
+	}
 }
 
-func (e *Engine) AddPeer(url string) {
+// RecordSender tags the sender of a gossip message with the region and zone
+// it advertised, so its liveness is judged with the matching timeout. A sender
+// that left and came back with a newer incarnation is no longer treated as
+// left.
+func (e *Engine) RecordSender(msg model.GossipMessage) {
 	e.nodeHealthMu.Lock()
-	defer e.nodeHealthMu.Unlock()
-	e.peers = append(e.peers, url)
+	if msg.Incarnation > e.incarnations[msg.SenderID] {
+		e.incarnations[msg.SenderID] = msg.Incarnation
+	}
+	if inc, gone := e.left[msg.SenderID]; gone && msg.Incarnation > inc {
+		delete(e.left, msg.SenderID)
+		delete(e.leftAt, msg.SenderID)
+		log.Printf("[GOSSIP] Node %s rejoined with incarnation %d", msg.SenderID, msg.Incarnation)
+	}
+	e.nodeHealthMu.Unlock()
+
+	e.peersMu.Lock()
+	defer e.peersMu.Unlock()
+	e.regions[msg.SenderID] = msg.Region
+	if p, ok := e.peers[msg.SenderID]; ok {
+		p.Region, p.Zone = msg.Region, msg.Zone
+		e.peers[msg.SenderID] = p
+	}
+}
+
+// AddPeer registers a peer by node ID, replacing the URL of an already known
+// peer. A join with an incarnation not newer than a recorded leave is rejected.
+func (e *Engine) AddPeer(nodeID, url, region, zone string, incarnation uint64) error {
+	e.nodeHealthMu.Lock()
+	if inc, gone := e.left[nodeID]; gone {
+		if incarnation <= inc {
+			e.nodeHealthMu.Unlock()
+			return fmt.Errorf("node %s left with incarnation %d, join has %d", nodeID, inc, incarnation)
+		}
+		delete(e.left, nodeID)
+		delete(e.leftAt, nodeID)
+	}
+	// Seed a heartbeat so a peer that never gossips still expires.
+	var events []MembershipEvent
+	if _, known := e.nodeHealth[nodeID]; !known {
+		entry := model.NodeHealth{
+			Heartbeat: time.Now(),
+			Metadata:  map[string]string{model.MetaRegion: region, model.MetaZone: zone},
+		}
+		e.nodeHealth[nodeID] = entry
+		e.status[nodeID] = model.MemberAlive
+		events = append(events, e.newEvent(EventJoined, nodeID, entry, model.MemberAlive))
+	}
+	e.nodeHealthMu.Unlock()
+	e.publish(events...)
+
+	e.peersMu.Lock()
+	defer e.peersMu.Unlock()
+	e.peers[nodeID] = peer{URL: url, Region: region, Zone: zone}
+	e.regions[nodeID] = region
+	return nil
+}
+
+// RemovePeer drops a peer and its health entry from the local view.
+func (e *Engine) RemovePeer(nodeID string) {
+	e.peersMu.Lock()
+	delete(e.peers, nodeID)
+	delete(e.regions, nodeID)
+	e.peersMu.Unlock()
+
+	e.nodeHealthMu.Lock()
+	delete(e.nodeHealth, nodeID)
+	delete(e.status, nodeID)
+	e.nodeHealthMu.Unlock()
+}
+
+// MarkLeft records that a node left the cluster and removes it from the peer
+// list. It returns false if the leave is not newer than one already recorded.
+func (e *Engine) MarkLeft(nodeID string, incarnation uint64) bool {
+	if nodeID == config.SelfID {
+		return false
+	}
+	e.nodeHealthMu.Lock()
+	if inc, gone := e.left[nodeID]; gone && inc >= incarnation {
+		e.nodeHealthMu.Unlock()
+		return false
+	}
+	// A leave from an earlier incarnation than the node gossiped with since
+	// is stale, typically relayed by a peer that missed the restart.
+	if incarnation < e.incarnations[nodeID] {
+		e.nodeHealthMu.Unlock()
+		return false
+	}
+	e.left[nodeID] = incarnation
+	e.leftAt[nodeID] = time.Now()
+	event := e.newEvent(EventLeft, nodeID, e.nodeHealth[nodeID], model.MemberLeft)
+	e.nodeHealthMu.Unlock()
+
+	e.RemovePeer(nodeID)
+	log.Printf("[GOSSIP] Node %s left (incarnation %d)", nodeID, incarnation)
+	e.publish(event)
+	return true
 }
 
+// MergeLeft applies leave records piggybacked on a gossip message.
+func (e *Engine) MergeLeft(left map[string]uint64) {
+	for id, inc := range left {
+		e.MarkLeft(id, inc)
+	}
+}
+
+// Leave broadcasts a leave message to every known peer and waits until all of
+// them were notified or ctx is done.
+func (e *Engine) Leave(ctx context.Context) error {
+	payload, err := json.Marshal(model.LeaveMessage{
+		NodeID:      config.SelfID,
+		Incarnation: e.incarnation,
+		Timestamp:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal leave message: %w", err)
+	}
+
+	e.peersMu.RLock()
+	urls := make([]string, 0, len(e.peers))
+	for _, p := range e.peers {
+		urls = append(urls, p.URL)
+	}
+	e.peersMu.RUnlock()
+
+	log.Printf("[GOSSIP] Leaving cluster, notifying %d peers", len(urls))
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/leave", bytes.NewReader(payload))
+			if err != nil {
+				log.Printf("[ERROR] Failed building leave request for %s: %v", url, err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				log.Printf("[ERROR] Failed sending leave to %s: %v", url, err)
+				return
+			}
+			resp.Body.Close()
+		}(url)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expireMembers marks members suspect once they miss heartbeats for half the
+// dead node timeout and drops their health entry once they miss it entirely. It also forgets
+// leave records once they can no longer be resurrected. Members in other
+// regions, or in an unknown one, get the WAN timeout.
+func (e *Engine) expireMembers() {
+	regions := e.getRegions()
+	now := time.Now()
+	leftCutoff := now.Add(-time.Duration(e.cfg.WANDeadNodeTimeoutMs) * time.Millisecond)
+
+	e.nodeHealthMu.Lock()
+	expired := make([]string, 0)
+	events := make([]MembershipEvent, 0)
+	for id, entry := range e.nodeHealth {
+		if id == config.SelfID {
+			continue
+		}
+		timeout := e.deadNodeTimeout(regions, id)
+		switch {
+		case entry.Heartbeat.Before(now.Add(-timeout)):
+			delete(e.nodeHealth, id)
+			delete(e.status, id)
+			expired = append(expired, id)
+			events = append(events, e.newEvent(EventDead, id, entry, model.MemberDead))
+		case entry.Heartbeat.Before(now.Add(-timeout/2)) && e.status[id] == model.MemberAlive:
+			e.status[id] = model.MemberSuspect
+			events = append(events, e.newEvent(EventSuspect, id, entry, model.MemberSuspect))
+		}
+	}
+	for id, at := range e.leftAt {
+		if at.Before(leftCutoff) {
+			delete(e.left, id)
+			delete(e.leftAt, id)
+		}
+	}
+	e.nodeHealthMu.Unlock()
+
+	// Dead members keep their gossip endpoint, so both sides of a healed
+	// partition find each other again; only their health state is dropped.
+	for _, id := range expired {
+		log.Printf("[GOSSIP] Expiring dead member %s", id)
+	}
+	e.publish(events...)
+}
+
+func (e *Engine) getLeft() map[string]uint64 {
+	e.nodeHealthMu.RLock()
+	defer e.nodeHealthMu.RUnlock()
+	if len(e.left) == 0 {
+		return nil
+	}
+	return maps.Clone(e.left)
+}
+
+func (e *Engine) getRegions() map[string]string {
+	e.peersMu.RLock()
+	defer e.peersMu.RUnlock()
+	return maps.Clone(e.regions)
+}
+
+// deadNodeTimeout returns the LAN timeout for members known to be in the local
+// region and the WAN timeout for everyone else.
+func (e *Engine) deadNodeTimeout(regions map[string]string, nodeID string) time.Duration {
+	if region, known := regions[nodeID]; known && region == e.cfg.Region {
+		return time.Duration(e.cfg.DeadNodeTimeoutMs) * time.Millisecond
+	}
+	return time.Duration(e.cfg.WANDeadNodeTimeoutMs) * time.Millisecond
+}
+
+// GetRandomPeers samples Fanout peers from the local region and
+// CrossRegionFanout peers from all other regions.
 func (e *Engine) GetRandomPeers() []string {
-	e.configLock.RLock()
-	defer e.configLock.RUnlock()
+	e.peersMu.RLock()
+	defer e.peersMu.RUnlock()
 
 	if len(e.peers) == 0 {
 		return []string{}
 	}
 
-	selected := make([]string, 0, e.cfg.Fanout)
-	perm := rand.Perm(len(e.peers))
-	for i := 0; i < e.cfg.Fanout && i < len(e.peers); i++ {
-		selected = append(selected, e.peers[perm[i]])
+	local := make([]string, 0, len(e.peers))
+	remote := make([]string, 0)
+	for _, p := range e.peers {
+		if p.Region == e.cfg.Region {
+			local = append(local, p.URL)
+		} else {
+			remote = append(remote, p.URL)
+		}
+	}
+	selected := make([]string, 0, e.cfg.Fanout+e.cfg.CrossRegionFanout)
+	selected = append(selected, samplePeers(local, e.cfg.Fanout)...)
+	selected = append(selected, samplePeers(remote, e.cfg.CrossRegionFanout)...)
+	return selected
+}
+
+// samplePeers picks up to n random entries from urls.
+func samplePeers(urls []string, n int) []string {
+	selected := make([]string, 0, n)
+	perm := rand.Perm(len(urls))
+	for i := 0; i < n && i < len(urls); i++ {
+		selected = append(selected, urls[perm[i]])
 	}
 	return selected
 }
@@ -31,40 +31,87 @@ func NewServer(listenAddress string, engine *Engine) *Server {
 
 func (s *Server) setupRoutes() {
 	s.router.GET("/health", s.handleHealth)
+	s.router.GET("/members", s.handleMembers)
 	s.router.POST("/gossip", s.handleGossip)
+	s.router.POST("/gossip/digest", s.handleDigest)
 	s.router.POST("/join", s.handleJoin)
+	s.router.POST("/leave", s.handleLeave)
 }
 
 func (s *Server) handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, s.engine.GetNodeHealth())
 }
 
+func (s *Server) handleMembers(c *gin.Context) {
+	c.JSON(http.StatusOK, s.engine.Members())
+}
+
 func (s *Server) handleGossip(c *gin.Context) {
 	var msg model.GossipMessage
 	if err := c.ShouldBindJSON(&msg); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	log.Printf("[RECV] Gossip received from %s", msg.SenderID)
+	log.Printf("[RECV] Gossip received from %s (region %s)", msg.SenderID, msg.Region)
 
+	s.engine.RecordSender(msg)
+	s.engine.MergeLeft(msg.Left)
 	s.engine.UpdateNodeHealth(msg.NodeHealth)
 
 	c.JSON(http.StatusOK, gin.H{"status": "received"})
 }
 
+func (s *Server) handleDigest(c *gin.Context) {
+	var msg model.GossipMessage
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[RECV] Gossip digest of %d nodes received from %s", len(msg.Digest), msg.SenderID)
+
+	s.engine.RecordSender(msg)
+	s.engine.MergeLeft(msg.Left)
+	delta, request := s.engine.Delta(msg.Digest)
+
+	c.JSON(http.StatusOK, model.DigestReply{Delta: delta, Request: request})
+}
+
 func (s *Server) handleJoin(c *gin.Context) {
 	var peer struct {
-		URL string `json:"url"`
+		NodeID      string `json:"nodeID" binding:"required"`
+		URL         string `json:"url" binding:"required"`
+		Region      string `json:"region"`
+		Zone        string `json:"zone"`
+		Incarnation uint64 `json:"incarnation"`
 	}
 	if err := c.ShouldBindJSON(&peer); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	s.engine.AddPeer(peer.URL)
-	log.Printf("[JOIN] Peer added: %s", peer.URL)
+	if err := s.engine.AddPeer(peer.NodeID, peer.URL, peer.Region, peer.Zone, peer.Incarnation); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[JOIN] Peer added: %s (%s)", peer.NodeID, peer.URL)
 	c.JSON(http.StatusOK, gin.H{"message": "Peer added"})
 }
 
+func (s *Server) handleLeave(c *gin.Context) {
+	var msg model.LeaveMessage
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.NodeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nodeID is required"})
+		return
+	}
+	if s.engine.MarkLeft(msg.NodeID, msg.Incarnation) {
+		log.Printf("[LEAVE] Peer removed: %s", msg.NodeID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Peer removed"})
+}
+
 func (s *Server) ListenAndServe() error {
 	log.Printf("[GOSSIP SERVER] Listening on %s\n", s.srv.Addr)
 	return s.srv.ListenAndServe()
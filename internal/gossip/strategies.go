@@ -14,6 +14,13 @@ type GossipStrategy interface {
 	Merge(local model.NodeHealthInfo, incoming model.GossipMessage) model.NodeHealthInfo
 }
 
+// DigestStrategy is implemented by initiation strategies that reconcile state
+// through a digest/delta exchange instead of shipping it in the message.
+type DigestStrategy interface {
+	GossipStrategy
+	GenerateDigest(state model.NodeHealthInfo) model.GossipMessage
+}
+
 // --- Spread Strategies ---
 
 func GetSpreadStrategy(name config.GossipSpreadStrategy) SpreadStrategy {
@@ -0,0 +1,218 @@
+package hashring
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Placement maps keys to the nodes that store them. HashRing, Rendezvous,
+// MultiProbe and Jump all implement it, so callers can switch algorithms
+// through configuration.
+type Placement interface {
+	AddNode(node ICacheNode) error
+	RemoveNode(node ICacheNode) error
+	SetNodeWeight(id string, weight int) error
+	UpdateNode(node ICacheNode) error
+	GetNode(id string) (ICacheNode, error)
+	GetPrimaryNode(key string) (ICacheNode, error)
+	GetNodesForKey(key string) ([]ICacheNode, error)
+	GetAllNodes() ([]ICacheNode, error)
+	PreferenceList(key string, isUp func(id string) bool) (*PreferenceList, error)
+}
+
+var (
+	_ Placement = (*HashRing)(nil)
+	_ Placement = (*Rendezvous)(nil)
+	_ Placement = (*MultiProbe)(nil)
+	_ Placement = (*Jump)(nil)
+)
+
+// Algorithm names a placement algorithm.
+type Algorithm string
+
+const (
+	// AlgorithmConsistent is consistent hashing with virtual nodes.
+	AlgorithmConsistent Algorithm = "consistent"
+	// AlgorithmRendezvous is highest random weight hashing.
+	AlgorithmRendezvous Algorithm = "rendezvous"
+	// AlgorithmMultiProbe is multi-probe consistent hashing.
+	AlgorithmMultiProbe Algorithm = "multiprobe"
+	// AlgorithmJump is jump consistent hashing.
+	AlgorithmJump Algorithm = "jump"
+)
+
+// Validate reports whether a names a known algorithm. An empty algorithm
+// selects consistent hashing.
+func (a Algorithm) Validate() error {
+	switch a {
+	case "", AlgorithmConsistent, AlgorithmRendezvous, AlgorithmMultiProbe, AlgorithmJump:
+		return nil
+	default:
+		return fmt.Errorf("unknown placement algorithm: %s", a)
+	}
+}
+
+// HasTokenRanges reports whether placements using a divide the hash space
+// into token ranges. Partition maps, range handoff and rebalancing, and the
+// per-range Merkle trees of anti-entropy all need them; only consistent
+// hashing has them.
+func (a Algorithm) HasTokenRanges() bool {
+	return a == "" || a == AlgorithmConsistent
+}
+
+// NewPlacement creates an empty placement using the given algorithm. An empty
+// algorithm selects consistent hashing.
+func NewPlacement(algorithm Algorithm, opts ...HashRingConfigFn) (Placement, error) {
+	switch algorithm {
+	case "", AlgorithmConsistent:
+		return InitHashRing(opts...), nil
+	case AlgorithmRendezvous:
+		return NewRendezvous(opts...), nil
+	case AlgorithmMultiProbe:
+		return NewMultiProbe(opts...), nil
+	case AlgorithmJump:
+		return NewJump(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown placement algorithm: %s", algorithm)
+	}
+}
+
+// SetProbes sets how many times multi-probe hashing hashes each key. More
+// probes even out load at the cost of slower lookups.
+func SetProbes(count int) HashRingConfigFn {
+	return func(cfg *hashRingConfig) {
+		cfg.Probes = count
+	}
+}
+
+// memberSet tracks the physical nodes and weights of placements that do not
+// keep them on a ring.
+type memberSet struct {
+	nodes   map[string]ICacheNode
+	weights map[string]int
+}
+
+func newMemberSet() memberSet {
+	return memberSet{nodes: make(map[string]ICacheNode), weights: make(map[string]int)}
+}
+
+// add registers node and returns its weight.
+func (m *memberSet) add(node ICacheNode) (int, error) {
+	id := node.GetIdentifier()
+	if _, exists := m.nodes[id]; exists {
+		return 0, ErrNodeExists
+	}
+	weight := weightOf(node)
+	if weight < 1 {
+		return 0, fmt.Errorf("%w: %s has weight %d", ErrInvalidWeight, id, weight)
+	}
+	m.nodes[id] = node
+	m.weights[id] = weight
+	return weight, nil
+}
+
+// replace swaps in a newer copy of a registered node and returns its old and
+// new weights.
+func (m *memberSet) replace(node ICacheNode) (int, int, error) {
+	id := node.GetIdentifier()
+	if _, ok := m.nodes[id]; !ok {
+		return 0, 0, ErrNodeNotFound
+	}
+	weight := weightOf(node)
+	if weight < 1 {
+		return 0, 0, fmt.Errorf("%w: %s has weight %d", ErrInvalidWeight, id, weight)
+	}
+	old := m.weights[id]
+	m.nodes[id] = node
+	m.weights[id] = weight
+	return old, weight, nil
+}
+
+// weightOf returns a node's weight, 1 for nodes without one.
+func weightOf(node ICacheNode) int {
+	if wn, ok := node.(IWeightedNode); ok {
+		return wn.GetWeight()
+	}
+	return 1
+}
+
+func (m *memberSet) remove(id string) error {
+	if _, ok := m.nodes[id]; !ok {
+		return ErrNodeNotFound
+	}
+	delete(m.nodes, id)
+	delete(m.weights, id)
+	return nil
+}
+
+// reweight checks a weight change and returns the old weight.
+func (m *memberSet) reweight(id string, weight int) (int, error) {
+	if _, ok := m.nodes[id]; !ok {
+		return 0, ErrNodeNotFound
+	}
+	if weight < 1 {
+		return 0, fmt.Errorf("%w: %s has weight %d", ErrInvalidWeight, id, weight)
+	}
+	old := m.weights[id]
+	m.weights[id] = weight
+	return old, nil
+}
+
+func (m *memberSet) get(id string) (ICacheNode, error) {
+	node, ok := m.nodes[id]
+	if !ok {
+		return nil, ErrNodeNotFound
+	}
+	return node, nil
+}
+
+// all returns the nodes sorted by identifier.
+func (m *memberSet) all() ([]ICacheNode, error) {
+	if len(m.nodes) == 0 {
+		return nil, ErrNoNodesAvailable
+	}
+	nodes := make([]ICacheNode, 0, len(m.nodes))
+	for _, n := range m.nodes {
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b ICacheNode) int {
+		return strings.Compare(a.GetIdentifier(), b.GetIdentifier())
+	})
+	return nodes, nil
+}
+
+// pickReplicas takes the replicas from candidates, which are distinct nodes in
+// order of preference, honouring the placement policy.
+func pickReplicas(candidates []ICacheNode, cfg *hashRingConfig) []ICacheNode {
+	if cfg.FailureDomain != DomainNone {
+		return spreadAcrossDomains(candidates, cfg.ReplicationFactor, cfg.FailureDomain)
+	}
+	return candidates[:min(cfg.ReplicationFactor, len(candidates))]
+}
+
+// candidatesFrom returns the nodes of owners in the order they are first met
+// walking the slice from start and wrapping around. Without a placement policy
+// the walk stops once there are enough replicas.
+func candidatesFrom(owners []ICacheNode, start int, cfg *hashRingConfig) []ICacheNode {
+	limit := len(owners)
+	if cfg.FailureDomain == DomainNone {
+		limit = cfg.ReplicationFactor
+	}
+	return distinctFrom(owners, start, limit)
+}
+
+// distinctFrom returns up to limit nodes of owners, each once, walking the
+// slice from start and wrapping around.
+func distinctFrom(owners []ICacheNode, start, limit int) []ICacheNode {
+	seen := make(map[string]struct{})
+	nodes := make([]ICacheNode, 0)
+	for i := 0; i < len(owners) && len(nodes) < limit; i++ {
+		n := owners[(start+i)%len(owners)]
+		if _, already := seen[n.GetIdentifier()]; !already {
+			seen[n.GetIdentifier()] = struct{}{}
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
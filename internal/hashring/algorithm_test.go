@@ -0,0 +1,242 @@
+package hashring
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"testing"
+)
+
+// testNode is a weighted node for tests.
+type testNode struct {
+	id     string
+	weight int
+}
+
+func (n testNode) GetIdentifier() string { return n.id }
+func (n testNode) GetWeight() int        { return n.weight }
+
+var algorithms = []Algorithm{AlgorithmConsistent, AlgorithmRendezvous, AlgorithmMultiProbe, AlgorithmJump}
+
+// newTestPlacement returns a placement of the given algorithm holding nodes
+// node-0 … node-(count-1), each of weight 1.
+func newTestPlacement(tb testing.TB, algorithm Algorithm, count int, opts ...HashRingConfigFn) Placement {
+	tb.Helper()
+	opts = append([]HashRingConfigFn{SetVirtualNodes(128)}, opts...)
+	p, err := NewPlacement(algorithm, opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := range count {
+		if err := p.AddNode(testNode{id: fmt.Sprintf("node-%d", i), weight: 1}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return p
+}
+
+func testKeys(count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	return keys
+}
+
+func primaries(tb testing.TB, p Placement, keys []string) []string {
+	tb.Helper()
+	owners := make([]string, len(keys))
+	for i, key := range keys {
+		node, err := p.GetPrimaryNode(key)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		owners[i] = node.GetIdentifier()
+	}
+	return owners
+}
+
+func TestPlacementIndependentOfJoinOrder(t *testing.T) {
+	keys := testKeys(2000)
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			nodes := make([]testNode, 8)
+			for i := range nodes {
+				nodes[i] = testNode{id: fmt.Sprintf("node-%d", i), weight: 1 + i%3}
+			}
+			build := func(order []testNode, drop int) Placement {
+				p, err := NewPlacement(algorithm)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, n := range order {
+					if err := p.AddNode(n); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := p.RemoveNode(nodes[drop]); err != nil {
+					t.Fatal(err)
+				}
+				return p
+			}
+
+			want := primaries(t, build(nodes, 3), keys)
+			shuffled := append([]testNode(nil), nodes...)
+			rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+			got := primaries(t, build(shuffled, 3), keys)
+			for i := range keys {
+				if got[i] != want[i] {
+					t.Fatalf("%s placed on %s after shuffled joins, %s in order", keys[i], got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPlacementLookup measures GetNodesForKey on a 64-node cluster.
+func BenchmarkPlacementLookup(b *testing.B) {
+	keys := testKeys(4096)
+	for _, algorithm := range algorithms {
+		b.Run(string(algorithm), func(b *testing.B) {
+			p := newTestPlacement(b, algorithm, 64)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.GetNodesForKey(keys[i%len(keys)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPlacementMemory reports the heap a 64-node placement retains, and
+// times and counts the allocations of adding one more node to it.
+func BenchmarkPlacementMemory(b *testing.B) {
+	for _, algorithm := range algorithms {
+		b.Run(string(algorithm), func(b *testing.B) {
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			p := newTestPlacement(b, algorithm, 64)
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+
+			extra := testNode{id: "node-extra", weight: 1}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.AddNode(extra); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				if err := p.RemoveNode(extra); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+			b.StopTimer()
+			runtime.KeepAlive(p)
+			b.ReportMetric(float64(retained), "heap-B")
+		})
+	}
+}
+
+// BenchmarkPlacementLoadVariance times primary lookups on a 64-node cluster
+// and reports the coefficient of variation of the primaries' key counts over
+// a fixed set of keys, so the figure does not depend on the benchmark time.
+func BenchmarkPlacementLoadVariance(b *testing.B) {
+	keys := testKeys(1 << 16)
+	for _, algorithm := range algorithms {
+		b.Run(string(algorithm), func(b *testing.B) {
+			p := newTestPlacement(b, algorithm, 64)
+			counts := make(map[string]int)
+			for _, owner := range primaries(b, p, keys) {
+				counts[owner]++
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.GetPrimaryNode(keys[i%len(keys)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(100*coefficientOfVariation(counts, 64), "cv%")
+		})
+	}
+}
+
+// BenchmarkPlacementMovement reports the share of keys whose primary changes
+// when a node joins or leaves a 64-node cluster, and times a join followed by
+// a leave.
+func BenchmarkPlacementMovement(b *testing.B) {
+	keys := testKeys(1 << 14)
+	for _, algorithm := range algorithms {
+		b.Run(string(algorithm), func(b *testing.B) {
+			p := newTestPlacement(b, algorithm, 64)
+			extra := testNode{id: "node-extra", weight: 1}
+			leaving := testNode{id: "node-17", weight: 1}
+
+			before := primaries(b, p, keys)
+			if err := p.AddNode(extra); err != nil {
+				b.Fatal(err)
+			}
+			added := primaries(b, p, keys)
+			if err := p.RemoveNode(extra); err != nil {
+				b.Fatal(err)
+			}
+			if err := p.RemoveNode(leaving); err != nil {
+				b.Fatal(err)
+			}
+			removed := primaries(b, p, keys)
+			if err := p.AddNode(leaving); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.AddNode(extra); err != nil {
+					b.Fatal(err)
+				}
+				if err := p.RemoveNode(extra); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(100*movedShare(before, added), "moved%/add")
+			b.ReportMetric(100*movedShare(before, removed), "moved%/remove")
+		})
+	}
+}
+
+// coefficientOfVariation returns the standard deviation of counts over nodes
+// nodes divided by their mean.
+func coefficientOfVariation(counts map[string]int, nodes int) float64 {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	mean := float64(total) / float64(nodes)
+	if mean == 0 {
+		return 0
+	}
+	var sum float64
+	for _, c := range counts {
+		d := float64(c) - mean
+		sum += d * d
+	}
+	sum += float64(nodes-len(counts)) * mean * mean
+	return math.Sqrt(sum/float64(nodes)) / mean
+}
+
+func movedShare(before, after []string) float64 {
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(before))
+}
@@ -0,0 +1,73 @@
+package hashring
+
+import "math"
+
+// SetLoadBound enables consistent hashing with bounded loads: no node is
+// handed more than (1+epsilon) times its weighted share of the total load,
+// and GetPrimaryNode walks past nodes at capacity. Smaller values balance
+// better but move more keys off their home node. 0 disables the bound.
+func SetLoadBound(epsilon float64) HashRingConfigFn {
+	return func(cfg *hashRingConfig) {
+		cfg.LoadBound = epsilon
+	}
+}
+
+// SetLoad records a node's current load, such as the number of requests it
+// served in the last reporting window.
+func (ring *HashRing) SetLoad(id string, load int64) error {
+	counter, ok := ring.snapshot().loads[id]
+	if !ok {
+		return ErrNodeNotFound
+	}
+	counter.Store(max(load, 0))
+	return nil
+}
+
+// AddLoad adjusts a node's load by delta, for callers that count requests as
+// they are routed.
+func (ring *HashRing) AddLoad(id string, delta int64) error {
+	counter, ok := ring.snapshot().loads[id]
+	if !ok {
+		return ErrNodeNotFound
+	}
+	counter.Add(delta)
+	return nil
+}
+
+// GetLoad returns the load last recorded for a node.
+func (ring *HashRing) GetLoad(id string) (int64, error) {
+	counter, ok := ring.snapshot().loads[id]
+	if !ok {
+		return 0, ErrNodeNotFound
+	}
+	return counter.Load(), nil
+}
+
+// boundedFrom returns the first node at or after the virtual node at index
+// start that has room for one more unit of load. A node's capacity is
+// ceil((1+ε) × (total+1) × weight / totalWeight); capacities add up to more
+// than the total, so some node always has room.
+func (s *ringState) boundedFrom(start int, epsilon float64) ICacheNode {
+	var total int64
+	totalWeight := 0
+	for id, counter := range s.loads {
+		total += counter.Load()
+		totalWeight += s.weights[id]
+	}
+	scale := (1 + epsilon) * float64(total+1) / float64(totalWeight)
+
+	checked := make(map[string]struct{})
+	for i := 0; i < len(s.owners) && len(checked) < len(s.nodes); i++ {
+		n := s.owners[(start+i)%len(s.owners)]
+		id := n.GetIdentifier()
+		if _, seen := checked[id]; seen {
+			continue
+		}
+		checked[id] = struct{}{}
+		capacity := int64(math.Ceil(scale * float64(s.weights[id])))
+		if s.loads[id].Load() < capacity {
+			return n
+		}
+	}
+	return s.owners[start]
+}
@@ -0,0 +1,94 @@
+package hashring
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// zipfLoads routes requests for Zipf-distributed keys to the primary ring
+// returns, counting each as one unit of load, and returns the total and the
+// highest load of any node.
+func zipfLoads(t *testing.T, ring *HashRing, requests int) (int64, int64) {
+	t.Helper()
+	zipf := rand.NewZipf(rand.New(rand.NewPCG(1, 2)), 1.1, 1, 9999)
+	for range requests {
+		node, err := ring.GetPrimaryNode(fmt.Sprintf("key-%d", zipf.Uint64()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ring.AddLoad(node.GetIdentifier(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var total, highest int64
+	nodes, err := ring.GetAllNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		load, err := ring.GetLoad(n.GetIdentifier())
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += load
+		highest = max(highest, load)
+	}
+	return total, highest
+}
+
+func newLoadRing(t *testing.T, nodes int, opts ...HashRingConfigFn) *HashRing {
+	t.Helper()
+	ring := InitHashRing(append([]HashRingConfigFn{SetVirtualNodes(64)}, opts...)...)
+	for i := range nodes {
+		if err := ring.AddNode(testNode{id: fmt.Sprintf("node-%d", i), weight: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ring
+}
+
+func TestBoundedLoadUnderZipf(t *testing.T) {
+	const nodes, requests = 16, 50_000
+	for _, epsilon := range []float64{0.1, 0.25, 1} {
+		t.Run(fmt.Sprintf("epsilon=%g", epsilon), func(t *testing.T) {
+			total, highest := zipfLoads(t, newLoadRing(t, nodes, SetLoadBound(epsilon)), requests)
+			bound := int64(math.Ceil((1 + epsilon) * float64(total) / nodes))
+			if highest > bound {
+				t.Errorf("busiest node served %d of %d requests, bound is %d", highest, total, bound)
+			}
+		})
+	}
+}
+
+func TestUnboundedLoadUnderZipf(t *testing.T) {
+	// Without a bound the node owning the hottest keys is far above its
+	// share, which is what the bound is there to prevent.
+	const nodes, requests = 16, 50_000
+	total, highest := zipfLoads(t, newLoadRing(t, nodes), requests)
+	if bound := int64(math.Ceil(1.25 * float64(total) / nodes)); highest <= bound {
+		t.Errorf("busiest node served %d of %d requests, expected skew above %d", highest, total, bound)
+	}
+}
+
+func TestBoundedLoadRespectsWeights(t *testing.T) {
+	const epsilon, requests = 0.25, 20_000
+	ring := InitHashRing(SetVirtualNodes(64), SetLoadBound(epsilon))
+	weights := map[string]int{"small": 1, "medium": 2, "large": 4}
+	for id, w := range weights {
+		if err := ring.AddNode(testNode{id: id, weight: w}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	total, _ := zipfLoads(t, ring, requests)
+	for id, w := range weights {
+		load, err := ring.GetLoad(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bound := int64(math.Ceil((1 + epsilon) * float64(total) * float64(w) / 7)); load > bound {
+			t.Errorf("%s (weight %d) served %d of %d requests, bound is %d", id, w, load, total, bound)
+		}
+	}
+}
@@ -6,9 +6,10 @@ import (
 	"hash"
 	"hash/fnv"
 	"log"
+	"maps"
 	"slices"
-	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -16,21 +17,44 @@ var (
 	ErrNodeExists       = errors.New("node already exists")
 	ErrNodeNotFound     = errors.New("node not found")
 	ErrHashingKey       = errors.New("failed to hash key")
+	ErrInvalidWeight    = errors.New("node weight must be positive")
 )
 
 type ICacheNode interface {
 	GetIdentifier() string
 }
 
+// IWeightedNode is implemented by nodes that should own a share of the ring
+// proportional to their weight. Nodes without a weight count as weight 1.
+type IWeightedNode interface {
+	ICacheNode
+	GetWeight() int
+}
+
 type hashRingConfig struct {
 	VirtualNodes      int
 	ReplicationFactor int
-	HashFunction      func() hash.Hash64
+	HashFunction      func() hash.Hash64 // nil selects FNV-1a with mix64
 	EnableLogs        bool
+	FailureDomain     FailureDomain
+	Probes            int
+	LoadBound         float64
 }
 
 type HashRingConfigFn func(*hashRingConfig)
 
+func newHashRingConfig(opts []HashRingConfigFn) *hashRingConfig {
+	cfg := &hashRingConfig{
+		VirtualNodes:      3,
+		ReplicationFactor: 2,
+		Probes:            21,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
 func SetVirtualNodes(count int) HashRingConfigFn {
 	return func(cfg *hashRingConfig) {
 		cfg.VirtualNodes = count
@@ -43,6 +67,8 @@ func SetReplicationFactor(replication int) HashRingConfigFn {
 	}
 }
 
+// SetHashFunction replaces the default hash. Its output is used as is, without
+// the mix64 finalizer the default FNV-1a hash gets.
 func SetHashFunction(f func() hash.Hash64) HashRingConfigFn {
 	return func(cfg *hashRingConfig) {
 		cfg.HashFunction = f
@@ -55,104 +81,272 @@ func EnableVerboseLogs(b bool) HashRingConfigFn {
 	}
 }
 
+// HashRing is consistent hashing with virtual nodes. Lookups read an
+// immutable snapshot without locking; membership changes build a new
+// snapshot and swap it in, so readers never wait on writers.
 type HashRing struct {
-	mu         sync.RWMutex
-	config     hashRingConfig
-	vNodeMap   sync.Map // hash → node
-	hostSet    sync.Map // nodeID → node
-	sortedKeys []uint64
+	mu     sync.Mutex // serializes writers
+	config hashRingConfig
+	state  atomic.Pointer[ringState]
+}
+
+// ringState is one immutable view of the ring. Virtual nodes are kept in two
+// parallel arrays sorted by token, so a lookup is a binary search followed by
+// an index, with no map access or pointer chasing per virtual node.
+type ringState struct {
+	tokens  []uint64                 // sorted virtual node tokens
+	owners  []ICacheNode             // owners[i] owns tokens[i]
+	nodes   map[string]ICacheNode    // nodeID → node
+	weights map[string]int           // nodeID → weight
+	loads   map[string]*atomic.Int64 // nodeID → load, shared between snapshots
 }
 
 func InitHashRing(opts ...HashRingConfigFn) *HashRing {
-	cfg := &hashRingConfig{
-		VirtualNodes:      3,
-		ReplicationFactor: 2,
-		HashFunction:      fnv.New64a,
-	}
-	for _, opt := range opts {
-		opt(cfg)
+	cfg := newHashRingConfig(opts)
+	ring := &HashRing{config: *cfg}
+	ring.state.Store(&ringState{
+		tokens:  make([]uint64, 0),
+		owners:  make([]ICacheNode, 0),
+		nodes:   make(map[string]ICacheNode),
+		weights: make(map[string]int),
+		loads:   make(map[string]*atomic.Int64),
+	})
+	return ring
+}
+
+// snapshot returns the current state. It must not be modified.
+func (ring *HashRing) snapshot() *ringState {
+	return ring.state.Load()
+}
+
+// update copies the current state's node maps, lets fn change the copy, and
+// publishes it unless fn fails. Callers hold ring.mu.
+func (ring *HashRing) update(fn func(next *ringState) error) error {
+	cur := ring.snapshot()
+	next := &ringState{
+		tokens:  cur.tokens,
+		owners:  cur.owners,
+		nodes:   maps.Clone(cur.nodes),
+		weights: maps.Clone(cur.weights),
+		loads:   maps.Clone(cur.loads),
 	}
-	return &HashRing{
-		config:     *cfg,
-		sortedKeys: make([]uint64, 0),
+	if err := fn(next); err != nil {
+		return err
 	}
+	ring.state.Store(next)
+	return nil
 }
 
 func (ring *HashRing) AddNode(node ICacheNode) error {
 	ring.mu.Lock()
 	defer ring.mu.Unlock()
 
-	id := node.GetIdentifier()
-	if _, exists := ring.hostSet.Load(id); exists {
-		return ErrNodeExists
+	return ring.update(func(next *ringState) error {
+		id := node.GetIdentifier()
+		if _, exists := next.nodes[id]; exists {
+			return ErrNodeExists
+		}
+
+		weight := weightOf(node)
+		if weight < 1 {
+			return fmt.Errorf("%w: %s has weight %d", ErrInvalidWeight, id, weight)
+		}
+
+		if err := ring.addVirtualNodes(next, node, 0, ring.vNodeCount(weight)); err != nil {
+			return err
+		}
+		next.nodes[id] = node
+		next.weights[id] = weight
+		next.loads[id] = new(atomic.Int64)
+		return nil
+	})
+}
+
+// SetNodeWeight changes the weight of a node already on the ring. Only the
+// virtual nodes making up the difference are added or removed, so keys owned
+// by the node's remaining virtual nodes do not move.
+func (ring *HashRing) SetNodeWeight(id string, weight int) error {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	return ring.update(func(next *ringState) error {
+		if _, ok := next.nodes[id]; !ok {
+			return ErrNodeNotFound
+		}
+		return ring.reweight(next, id, weight)
+	})
+}
+
+// UpdateNode replaces a node already on the ring with a newer copy of it, such
+// as a member whose advertised metadata changed. Its virtual nodes stay where
+// they are unless its weight changed.
+func (ring *HashRing) UpdateNode(node ICacheNode) error {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	return ring.update(func(next *ringState) error {
+		id := node.GetIdentifier()
+		if _, ok := next.nodes[id]; !ok {
+			return ErrNodeNotFound
+		}
+		next.nodes[id] = node
+		if err := ring.reweight(next, id, weightOf(node)); err != nil {
+			return err
+		}
+		owners := slices.Clone(next.owners)
+		for i := range owners {
+			if owners[i].GetIdentifier() == id {
+				owners[i] = node
+			}
+		}
+		next.owners = owners
+		return nil
+	})
+}
+
+// reweight adds or removes the virtual nodes making up the difference between
+// a node's current weight and weight.
+func (ring *HashRing) reweight(next *ringState, id string, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("%w: %s has weight %d", ErrInvalidWeight, id, weight)
+	}
+	oldCount, newCount := ring.vNodeCount(next.weights[id]), ring.vNodeCount(weight)
+	switch {
+	case newCount > oldCount:
+		if err := ring.addVirtualNodes(next, next.nodes[id], oldCount, newCount); err != nil {
+			return err
+		}
+	case newCount < oldCount:
+		removed := make(map[uint64]struct{}, oldCount-newCount)
+		for i := newCount; i < oldCount; i++ {
+			vID := fmt.Sprintf("%s#%d", id, i)
+			h, err := ring.generateHash(vID)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrHashingKey, vID)
+			}
+			removed[h] = struct{}{}
+		}
+		next.removeTokens(func(i int) bool {
+			_, ok := removed[next.tokens[i]]
+			return ok && next.owners[i].GetIdentifier() == id
+		})
+	}
+	next.weights[id] = weight
+	return nil
+}
+
+// GetNodeWeight returns the weight a node was placed on the ring with.
+func (ring *HashRing) GetNodeWeight(id string) (int, error) {
+	weight, ok := ring.snapshot().weights[id]
+	if !ok {
+		return 0, ErrNodeNotFound
 	}
+	return weight, nil
+}
 
-	for i := 0; i < ring.config.VirtualNodes; i++ {
+// addVirtualNodes places virtual nodes from..to-1 of node on the ring,
+// merging them into the sorted arrays of next.
+func (ring *HashRing) addVirtualNodes(next *ringState, node ICacheNode, from, to int) error {
+	id := node.GetIdentifier()
+	added := make([]uint64, 0, to-from)
+	for i := from; i < to; i++ {
 		vID := fmt.Sprintf("%s#%d", id, i)
 		h, err := ring.generateHash(vID)
 		if err != nil {
 			return fmt.Errorf("%w: %s", ErrHashingKey, vID)
 		}
-		ring.vNodeMap.Store(h, node)
-		ring.sortedKeys = append(ring.sortedKeys, h)
+		added = append(added, h)
 
 		if ring.config.EnableLogs {
 			log.Printf("🧩 Virtual node added %s → %d", vID, h)
 		}
 	}
-	ring.hostSet.Store(id, node)
-	slices.Sort(ring.sortedKeys)
+	slices.Sort(added)
+
+	tokens := make([]uint64, 0, len(next.tokens)+len(added))
+	owners := make([]ICacheNode, 0, len(next.tokens)+len(added))
+	i, j := 0, 0
+	for i < len(next.tokens) || j < len(added) {
+		if j == len(added) || (i < len(next.tokens) && next.tokens[i] <= added[j]) {
+			tokens = append(tokens, next.tokens[i])
+			owners = append(owners, next.owners[i])
+			i++
+			continue
+		}
+		tokens = append(tokens, added[j])
+		owners = append(owners, node)
+		j++
+	}
+	next.tokens, next.owners = tokens, owners
 	return nil
 }
 
+// removeTokens drops the virtual nodes for which drop returns true, building
+// new arrays so earlier snapshots are left intact.
+func (s *ringState) removeTokens(drop func(i int) bool) {
+	tokens := make([]uint64, 0, len(s.tokens))
+	owners := make([]ICacheNode, 0, len(s.owners))
+	for i := range s.tokens {
+		if drop(i) {
+			continue
+		}
+		tokens = append(tokens, s.tokens[i])
+		owners = append(owners, s.owners[i])
+	}
+	s.tokens, s.owners = tokens, owners
+}
+
+// vNodeCount scales the configured virtual nodes by weight.
+func (ring *HashRing) vNodeCount(weight int) int {
+	return ring.config.VirtualNodes * weight
+}
+
 func (ring *HashRing) RemoveNode(node ICacheNode) error {
 	ring.mu.Lock()
 	defer ring.mu.Unlock()
 
-	id := node.GetIdentifier()
-	if _, ok := ring.hostSet.Load(id); !ok {
-		return ErrNodeNotFound
-	}
-	ring.hostSet.Delete(id)
-
-	// remove all virtual nodes
-	newKeys := make([]uint64, 0, len(ring.sortedKeys))
-	for _, h := range ring.sortedKeys {
-		val, err := ring.getNodeByHash(h)
-		if err == nil && val.GetIdentifier() == id {
-			ring.vNodeMap.Delete(h)
-			continue
+	return ring.update(func(next *ringState) error {
+		id := node.GetIdentifier()
+		if _, ok := next.nodes[id]; !ok {
+			return ErrNodeNotFound
 		}
-		newKeys = append(newKeys, h)
-	}
-	ring.sortedKeys = newKeys
-	return nil
+		delete(next.nodes, id)
+		delete(next.weights, id)
+		delete(next.loads, id)
+
+		// remove all virtual nodes
+		next.removeTokens(func(i int) bool {
+			return next.owners[i].GetIdentifier() == id
+		})
+		return nil
+	})
 }
 
-// ✅ GetPrimaryNode returns just one node (like V1 & V2)
+// ✅ GetPrimaryNode returns just one node (like V1 & V2). With a load bound
+// set, nodes at capacity are skipped in favour of the next node on the ring.
 func (ring *HashRing) GetPrimaryNode(key string) (ICacheNode, error) {
-	ring.mu.RLock()
-	defer ring.mu.RUnlock()
+	s := ring.snapshot()
+	if len(s.tokens) == 0 {
+		return nil, ErrNoNodesAvailable
+	}
 
 	h, err := ring.generateHash(key)
 	if err != nil {
 		return nil, err
 	}
 
-	idx := ring.search(h)
-	node, err := ring.getNodeByHash(ring.sortedKeys[idx])
-	if err != nil {
-		return nil, err
+	idx := s.search(h)
+	if ring.config.LoadBound > 0 {
+		return s.boundedFrom(idx, ring.config.LoadBound), nil
 	}
-	return node, nil
+	return s.owners[idx], nil
 }
 
-// ✅ GetNodesForKey returns N unique physical nodes for redundancy
+// ✅ GetNodesForKey returns N unique physical nodes for redundancy. Replicas
+// ignore the load bound, so data never moves because a node is busy.
 func (ring *HashRing) GetNodesForKey(key string) ([]ICacheNode, error) {
-	ring.mu.RLock()
-	defer ring.mu.RUnlock()
-
-	if len(ring.sortedKeys) == 0 {
+	s := ring.snapshot()
+	if len(s.tokens) == 0 {
 		return nil, ErrNoNodesAvailable
 	}
 
@@ -160,85 +354,76 @@ func (ring *HashRing) GetNodesForKey(key string) ([]ICacheNode, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ring.nodesFrom(s, s.search(h)), nil
+}
 
-	seen := make(map[string]struct{})
-	nodes := make([]ICacheNode, 0, ring.config.ReplicationFactor)
-
-	start := ring.search(h)
-	i := start
-
-	for len(nodes) < ring.config.ReplicationFactor {
-		vHash := ring.sortedKeys[i%len(ring.sortedKeys)]
-		n, err := ring.getNodeByHash(vHash)
-		if err != nil {
-			if errors.Is(err, ErrNodeNotFound) {
-				i++
-				continue
-			}
-			return nil, err
-		}
-
-		id := n.GetIdentifier()
-		if _, already := seen[id]; !already {
-			seen[id] = struct{}{}
-			nodes = append(nodes, n)
-		}
-		i++
-		if i-start > len(ring.sortedKeys) {
-			break // avoid infinite loop if not enough unique nodes
-		}
-	}
+// nodesFrom returns the replicas for a position on the ring: up to
+// ReplicationFactor distinct nodes, starting at the virtual node at index
+// start and placed according to the placement policy.
+func (ring *HashRing) nodesFrom(s *ringState, start int) []ICacheNode {
+	return pickReplicas(candidatesFrom(s.owners, start, &ring.config), &ring.config)
+}
 
-	if len(nodes) == 0 {
-		return nil, ErrNoNodesAvailable
+// GetNode returns the node with the given identifier.
+func (ring *HashRing) GetNode(id string) (ICacheNode, error) {
+	node, ok := ring.snapshot().nodes[id]
+	if !ok {
+		return nil, ErrNodeNotFound
 	}
-	return nodes, nil
+	return node, nil
 }
 
 // ✅ GetAllNodes returns all nodes
 func (ring *HashRing) GetAllNodes() ([]ICacheNode, error) {
-	ring.mu.RLock()
-	defer ring.mu.RUnlock()
-
-	if len(ring.sortedKeys) == 0 {
+	s := ring.snapshot()
+	if len(s.tokens) == 0 {
 		return nil, ErrNoNodesAvailable
 	}
-	allNodes := make([]ICacheNode, 0)
-	ring.hostSet.Range(func(_, value any) bool {
-		allNodes = append(allNodes, value.(ICacheNode))
-		return true
-	})
-	return allNodes, nil
-}
-
-// getNodeByHash
-func (ring *HashRing) getNodeByHash(key uint64) (ICacheNode, error) {
-	val, ok := ring.vNodeMap.Load(key)
-	if !ok {
-		return nil, fmt.Errorf("%w for hash %v", ErrNodeNotFound, key)
+	allNodes := make([]ICacheNode, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		allNodes = append(allNodes, n)
 	}
-	node, ok := val.(ICacheNode)
-	if !ok {
-		return nil, fmt.Errorf("invalid node type for hash %v", key)
-	}
-	return node, nil
+	return allNodes, nil
 }
 
-// 🧠 search returns index of first key ≥ hash or wraps around
-func (ring *HashRing) search(h uint64) int {
-	idx := sort.Search(len(ring.sortedKeys), func(i int) bool {
-		return ring.sortedKeys[i] >= h
-	})
-	if idx == len(ring.sortedKeys) {
+// 🧠 search returns index of first token ≥ hash or wraps around
+func (s *ringState) search(h uint64) int {
+	idx, _ := slices.BinarySearch(s.tokens, h)
+	if idx == len(s.tokens) {
 		return 0
 	}
 	return idx
 }
 
 func (ring *HashRing) generateHash(key string) (uint64, error) {
-	h := ring.config.HashFunction()
+	return ring.config.hash(key)
+}
+
+// hash places key in the hash space with the configured hash function, or
+// with FNV-1a followed by mix64 if none was set.
+func (cfg *hashRingConfig) hash(key string) (uint64, error) {
+	newHash := cfg.HashFunction
+	if newHash == nil {
+		newHash = fnv.New64a
+	}
+	h := newHash()
 	if _, err := h.Write([]byte(key)); err != nil {
 		return 0, err
 	}
-	return h.Sum64(), nil
+	if cfg.HashFunction != nil {
+		return h.Sum64(), nil
+	}
+	return mix64(h.Sum64()), nil
+}
+
+// mix64 is the murmur3 finalizer. FNV leaves near-identical inputs such as
+// "node#1" and "node#2" close together, which skews ownership away from the
+// node weights; mixing spreads them over the whole ring.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
 }
@@ -0,0 +1,171 @@
+package hashring
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"testing"
+)
+
+// maxWeightDeviation is how far, relative to its weighted share, any node's
+// share of keys may stray. Multi-probe hashing keeps one ring point per unit
+// of weight, so small clusters balance less tightly than with 256 virtual
+// nodes.
+var maxWeightDeviation = map[Algorithm]float64{
+	AlgorithmConsistent: 0.10,
+	AlgorithmRendezvous: 0.10,
+	AlgorithmMultiProbe: 0.25,
+	AlgorithmJump:       0.10,
+}
+
+// checkWeightedShares places keys and fails if any node's share of primaries
+// deviates from its share of the total weight by more than the algorithm's
+// maxWeightDeviation.
+func checkWeightedShares(t *testing.T, algorithm Algorithm, p Placement, weights map[string]int, keys []string) {
+	t.Helper()
+	tolerance := maxWeightDeviation[algorithm]
+	counts := make(map[string]int, len(weights))
+	for _, owner := range primaries(t, p, keys) {
+		counts[owner]++
+	}
+	totalWeight := 0
+	for _, w := range weights {
+		totalWeight += w
+	}
+	for id, w := range weights {
+		want := float64(len(keys)) * float64(w) / float64(totalWeight)
+		if dev := math.Abs(float64(counts[id])-want) / want; dev > tolerance {
+			t.Errorf("%s (weight %d) got %d keys, want %.0f ± %.0f%% (off by %.1f%%)",
+				id, w, counts[id], want, 100*tolerance, 100*dev)
+		}
+	}
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	keys := testKeys(100_000)
+	weights := map[string]int{"small-0": 1, "small-1": 1, "medium-0": 2, "medium-1": 2, "large-0": 4, "xlarge-0": 8}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			p, err := NewPlacement(algorithm, SetVirtualNodes(256))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, w := range weights {
+				if err := p.AddNode(testNode{id: id, weight: w}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkWeightedShares(t, algorithm, p, weights, keys)
+		})
+	}
+}
+
+func TestReweightDistribution(t *testing.T) {
+	keys := testKeys(100_000)
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			p, err := NewPlacement(algorithm, SetVirtualNodes(256))
+			if err != nil {
+				t.Fatal(err)
+			}
+			weights := make(map[string]int)
+			for i := range 6 {
+				id := fmt.Sprintf("node-%d", i)
+				weights[id] = 2
+				if err := p.AddNode(testNode{id: id, weight: 2}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			weights["node-0"], weights["node-1"] = 6, 1
+			if err := p.SetNodeWeight("node-0", 6); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.SetNodeWeight("node-1", 1); err != nil {
+				t.Fatal(err)
+			}
+			checkWeightedShares(t, algorithm, p, weights, keys)
+
+			if err := p.SetNodeWeight("node-9", 3); err == nil {
+				t.Error("reweighting an unknown node succeeded")
+			}
+			if err := p.SetNodeWeight("node-2", 0); err == nil {
+				t.Error("reweighting to zero succeeded")
+			}
+		})
+	}
+}
+
+func TestUpdateNodeKeepsPlacement(t *testing.T) {
+	keys := testKeys(10_000)
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			p := newTestPlacement(t, algorithm, 8)
+			before := primaries(t, p, keys)
+			if err := p.UpdateNode(testNode{id: "node-3", weight: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if moved := movedShare(before, primaries(t, p, keys)); moved != 0 {
+				t.Errorf("%.1f%% of keys moved after updating a node without changing its weight", 100*moved)
+			}
+		})
+	}
+}
+
+// BenchmarkGetNodesForKeyConcurrentMembership looks keys up from every
+// processor while another goroutine keeps adding and removing a node.
+// Lookups on the copy-on-write ring never wait for the membership changes.
+func BenchmarkGetNodesForKeyConcurrentMembership(b *testing.B) {
+	keys := testKeys(4096)
+	for _, algorithm := range algorithms {
+		b.Run(string(algorithm), func(b *testing.B) {
+			p := newTestPlacement(b, algorithm, 64)
+			extra := testNode{id: "node-extra", weight: 1}
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					_ = p.AddNode(extra)
+					_ = p.RemoveNode(extra)
+				}
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if _, err := p.GetNodesForKey(keys[i%len(keys)]); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			<-done
+		})
+	}
+}
+
+func TestCustomHashFunctionIsNotMixed(t *testing.T) {
+	const key = "key-42"
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	raw := h.Sum64()
+
+	custom := InitHashRing(SetHashFunction(fnv.New64a))
+	if token, err := custom.Token(key); err != nil || token != raw {
+		t.Fatalf("custom hash placed %s at %d (%v), want its raw output %d", key, token, err, raw)
+	}
+	if token, err := InitHashRing().Token(key); err != nil || token != mix64(raw) {
+		t.Fatalf("default hash placed %s at %d (%v), want mixed FNV-1a %d", key, token, err, mix64(raw))
+	}
+}
@@ -0,0 +1,142 @@
+package hashring
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Jump places keys with jump consistent hashing over a list of buckets, one
+// per unit of node weight. Lookups need no memory beyond the bucket list and
+// spread keys almost perfectly evenly. Buckets are laid out by node ID rather
+// than by arrival order, so every node that knows the same members computes
+// the same placement. The price is extra movement: jump hashing only moves
+// keys minimally when buckets change at the end of the list, and a member
+// change shifts every bucket after the changed node's.
+type Jump struct {
+	mu      sync.RWMutex
+	config  hashRingConfig
+	members memberSet
+	buckets []ICacheNode
+}
+
+func NewJump(opts ...HashRingConfigFn) *Jump {
+	return &Jump{
+		config:  *newHashRingConfig(opts),
+		members: newMemberSet(),
+	}
+}
+
+func (j *Jump) AddNode(node ICacheNode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.members.add(node); err != nil {
+		return err
+	}
+	j.rebuild()
+	return nil
+}
+
+func (j *Jump) RemoveNode(node ICacheNode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.members.remove(node.GetIdentifier()); err != nil {
+		return err
+	}
+	j.rebuild()
+	return nil
+}
+
+func (j *Jump) SetNodeWeight(id string, weight int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.members.reweight(id, weight); err != nil {
+		return err
+	}
+	j.rebuild()
+	return nil
+}
+
+// UpdateNode replaces a node with a newer copy of it, reweighting it if its
+// weight changed.
+func (j *Jump) UpdateNode(node ICacheNode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, _, err := j.members.replace(node); err != nil {
+		return err
+	}
+	j.rebuild()
+	return nil
+}
+
+// rebuild lays out the buckets from the members alone: nodes in ID order,
+// each with one bucket per unit of weight.
+func (j *Jump) rebuild() {
+	ids := slices.Sorted(maps.Keys(j.members.nodes))
+	buckets := make([]ICacheNode, 0, len(j.buckets))
+	for _, id := range ids {
+		node := j.members.nodes[id]
+		for range j.members.weights[id] {
+			buckets = append(buckets, node)
+		}
+	}
+	j.buckets = buckets
+}
+
+func (j *Jump) GetNode(id string) (ICacheNode, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.members.get(id)
+}
+
+func (j *Jump) GetAllNodes() ([]ICacheNode, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.members.all()
+}
+
+func (j *Jump) GetPrimaryNode(key string) (ICacheNode, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	b, err := j.bucket(key)
+	if err != nil {
+		return nil, err
+	}
+	return j.buckets[b], nil
+}
+
+// GetNodesForKey returns the primary followed by the next distinct nodes in
+// bucket order.
+func (j *Jump) GetNodesForKey(key string) ([]ICacheNode, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	b, err := j.bucket(key)
+	if err != nil {
+		return nil, err
+	}
+	return pickReplicas(candidatesFrom(j.buckets, b, &j.config), &j.config), nil
+}
+
+func (j *Jump) bucket(key string) (int, error) {
+	if len(j.buckets) == 0 {
+		return 0, ErrNoNodesAvailable
+	}
+	h, err := j.config.hash(key)
+	if err != nil {
+		return 0, err
+	}
+	return jumpHash(h, len(j.buckets)), nil
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach.
+func jumpHash(key uint64, buckets int) int {
+	var b, next int64 = -1, 0
+	for next < int64(buckets) {
+		b = next
+		key = key*2862933555777941757 + 1
+		next = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
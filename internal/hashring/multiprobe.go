@@ -0,0 +1,164 @@
+package hashring
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MultiProbe places keys with multi-probe consistent hashing: each node sits
+// on the ring once per unit of weight, and a key is hashed Probes times,
+// belonging to the node closest after any of its probes. This evens out load
+// like many virtual nodes would while keeping the ring small.
+type MultiProbe struct {
+	mu      sync.RWMutex
+	config  hashRingConfig
+	members memberSet
+	points  []uint64     // sorted node positions
+	owners  []ICacheNode // owners[i] sits at points[i]
+}
+
+func NewMultiProbe(opts ...HashRingConfigFn) *MultiProbe {
+	return &MultiProbe{
+		config:  *newHashRingConfig(opts),
+		members: newMemberSet(),
+	}
+}
+
+func (m *MultiProbe) AddNode(node ICacheNode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.members.add(node); err != nil {
+		return err
+	}
+	return m.rebuild()
+}
+
+func (m *MultiProbe) RemoveNode(node ICacheNode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.members.remove(node.GetIdentifier()); err != nil {
+		return err
+	}
+	return m.rebuild()
+}
+
+func (m *MultiProbe) SetNodeWeight(id string, weight int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.members.reweight(id, weight); err != nil {
+		return err
+	}
+	return m.rebuild()
+}
+
+// UpdateNode replaces a node with a newer copy of it, reweighting it if its
+// weight changed.
+func (m *MultiProbe) UpdateNode(node ICacheNode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, _, err := m.members.replace(node); err != nil {
+		return err
+	}
+	return m.rebuild()
+}
+
+// rebuild recomputes the node positions. A node's i-th position does not
+// depend on its weight, so reweighting only adds or drops positions.
+func (m *MultiProbe) rebuild() error {
+	type point struct {
+		hash uint64
+		node ICacheNode
+	}
+	points := make([]point, 0)
+	for id, n := range m.members.nodes {
+		for i := range m.members.weights[id] {
+			pID := fmt.Sprintf("%s#%d", id, i)
+			h, err := m.config.hash(pID)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrHashingKey, pID)
+			}
+			points = append(points, point{hash: h, node: n})
+		}
+	}
+	slices.SortFunc(points, func(a, b point) int {
+		switch {
+		case a.hash < b.hash:
+			return -1
+		case a.hash > b.hash:
+			return 1
+		default:
+			return 0
+		}
+	})
+	m.points = make([]uint64, len(points))
+	m.owners = make([]ICacheNode, len(points))
+	for i, p := range points {
+		m.points[i], m.owners[i] = p.hash, p.node
+	}
+	return nil
+}
+
+func (m *MultiProbe) GetNode(id string) (ICacheNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.members.get(id)
+}
+
+func (m *MultiProbe) GetAllNodes() ([]ICacheNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.members.all()
+}
+
+func (m *MultiProbe) GetPrimaryNode(key string) (ICacheNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, err := m.closest(key)
+	if err != nil {
+		return nil, err
+	}
+	return m.owners[idx], nil
+}
+
+// GetNodesForKey returns the primary followed by the next distinct nodes
+// after its position on the ring.
+func (m *MultiProbe) GetNodesForKey(key string) ([]ICacheNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, err := m.closest(key)
+	if err != nil {
+		return nil, err
+	}
+	return pickReplicas(candidatesFrom(m.owners, idx, &m.config), &m.config), nil
+}
+
+// closest returns the index of the position nearest after any probe of key.
+// Probes are derived by double hashing, so the key is only hashed once.
+func (m *MultiProbe) closest(key string) (int, error) {
+	if len(m.points) == 0 {
+		return 0, ErrNoNodesAvailable
+	}
+	h1, err := m.config.hash(key)
+	if err != nil {
+		return 0, err
+	}
+	h2 := mix64(h1^0x9e3779b97f4a7c15) | 1
+
+	best, bestDist := 0, uint64(0)
+	for i := range max(m.config.Probes, 1) {
+		probe := h1 + uint64(i)*h2
+		idx := sort.Search(len(m.points), func(j int) bool { return m.points[j] >= probe })
+		if idx == len(m.points) {
+			idx = 0
+		}
+		// Unsigned subtraction wraps, giving the clockwise distance.
+		if dist := m.points[idx] - probe; i == 0 || dist < bestDist {
+			best, bestDist = idx, dist
+		}
+	}
+	return best, nil
+}
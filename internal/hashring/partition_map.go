@@ -0,0 +1,218 @@
+package hashring
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// TokenRange is the arc (Start, End] of the hash space. A range whose Start is
+// greater than its End wraps around zero; Start equal to End covers the whole
+// ring.
+type TokenRange struct {
+	Start uint64 `json:"start" yaml:"start"` // Exclusive lower bound
+	End   uint64 `json:"end" yaml:"end"`     // Inclusive upper bound
+}
+
+// Contains reports whether token falls inside the range.
+func (r TokenRange) Contains(token uint64) bool {
+	if r.Start < r.End {
+		return token > r.Start && token <= r.End
+	}
+	return token > r.Start || token <= r.End
+}
+
+func (r TokenRange) String() string {
+	return fmt.Sprintf("(%d, %d]", r.Start, r.End)
+}
+
+// Partition is a token range with the nodes that replicate it, primary first.
+type Partition struct {
+	Range    TokenRange `json:"range" yaml:"range"`       // Tokens owned by this partition
+	Replicas []string   `json:"replicas" yaml:"replicas"` // Node IDs of the replicas, primary first
+	Epoch    uint64     `json:"epoch" yaml:"epoch"`       // Map epoch at which the replicas last changed
+}
+
+// PartitionMap is a versioned snapshot of token range ownership. Partitions
+// are sorted by Range.End, so the first one is the one wrapping around zero.
+type PartitionMap struct {
+	Epoch      uint64      `json:"epoch" yaml:"epoch"`           // Bumped every time ownership changes
+	Partitions []Partition `json:"partitions" yaml:"partitions"` // Token ranges, sorted by end token
+}
+
+// RangeChange is a token range whose replicas differ between two maps.
+type RangeChange struct {
+	Range       TokenRange `json:"range" yaml:"range"`             // Tokens that changed owners
+	OldReplicas []string   `json:"oldReplicas" yaml:"oldReplicas"` // Replicas in the older map
+	NewReplicas []string   `json:"newReplicas" yaml:"newReplicas"` // Replicas in the newer map
+}
+
+// PartitionMap derives the partition map of the current ring. Adjacent ranges
+// with the same replicas are merged. When prev is given, the epoch moves past
+// prev's only if ownership changed, and partitions whose replicas did not
+// change keep their epoch.
+func (ring *HashRing) PartitionMap(prev *PartitionMap) (*PartitionMap, error) {
+	s := ring.snapshot()
+	if len(s.tokens) == 0 {
+		return nil, ErrNoNodesAvailable
+	}
+
+	partitions := make([]Partition, 0, len(s.tokens))
+	for i, end := range s.tokens {
+		start := s.tokens[(i+len(s.tokens)-1)%len(s.tokens)]
+		nodes := ring.nodesFrom(s, i)
+		replicas := make([]string, len(nodes))
+		for j, n := range nodes {
+			replicas[j] = n.GetIdentifier()
+		}
+		partitions = append(partitions, Partition{Range: TokenRange{Start: start, End: end}, Replicas: replicas})
+	}
+	pm := &PartitionMap{Partitions: mergePartitions(partitions)}
+
+	if prev == nil {
+		pm.Epoch = 1
+		for i := range pm.Partitions {
+			pm.Partitions[i].Epoch = pm.Epoch
+		}
+		return pm, nil
+	}
+
+	pm.Epoch = prev.Epoch + 1
+	changed := false
+	for i := range pm.Partitions {
+		if epoch, same := prev.unchangedEpoch(pm.Partitions[i]); same {
+			pm.Partitions[i].Epoch = epoch
+			continue
+		}
+		pm.Partitions[i].Epoch = pm.Epoch
+		changed = true
+	}
+	if !changed {
+		return prev.clone(), nil
+	}
+	return pm, nil
+}
+
+// mergePartitions joins neighbouring partitions with identical replicas,
+// including the last one into the first across zero.
+func mergePartitions(partitions []Partition) []Partition {
+	merged := make([]Partition, 0, len(partitions))
+	for _, p := range partitions {
+		if n := len(merged); n > 0 && slices.Equal(merged[n-1].Replicas, p.Replicas) {
+			merged[n-1].Range.End = p.Range.End
+			continue
+		}
+		merged = append(merged, p)
+	}
+	if n := len(merged); n > 1 && slices.Equal(merged[0].Replicas, merged[n-1].Replicas) {
+		merged[0].Range.Start = merged[n-1].Range.Start
+		merged = merged[:n-1]
+	}
+	return merged
+}
+
+// Token returns the position of key on the ring, for looking it up in a
+// partition map.
+func (ring *HashRing) Token(key string) (uint64, error) {
+	return ring.generateHash(key)
+}
+
+// Owners returns the partition containing token.
+func (pm *PartitionMap) Owners(token uint64) Partition {
+	if len(pm.Partitions) == 0 {
+		return Partition{}
+	}
+	idx := sort.Search(len(pm.Partitions), func(i int) bool {
+		return pm.Partitions[i].Range.End >= token
+	})
+	if idx == len(pm.Partitions) {
+		idx = 0
+	}
+	return pm.Partitions[idx]
+}
+
+// unchangedEpoch reports whether pm assigns the replicas of p to every token
+// of p's range, and if so the latest epoch among the partitions involved.
+func (pm *PartitionMap) unchangedEpoch(p Partition) (uint64, bool) {
+	owner := pm.Owners(p.Range.End)
+	if !slices.Equal(owner.Replicas, p.Replicas) {
+		return 0, false
+	}
+	epoch := owner.Epoch
+	for _, other := range pm.Partitions {
+		if other.Range.End == p.Range.End || !p.Range.Contains(other.Range.End) {
+			continue
+		}
+		// other ends inside p, so it used to own part of p's range.
+		if !slices.Equal(other.Replicas, p.Replicas) {
+			return 0, false
+		}
+		epoch = max(epoch, other.Epoch)
+	}
+	return epoch, true
+}
+
+// Diff returns the token ranges whose replicas differ from those in old,
+// with neighbouring changes that have the same old and new replicas merged.
+func (pm *PartitionMap) Diff(old *PartitionMap) []RangeChange {
+	bounds := make([]uint64, 0, len(pm.Partitions)+len(old.Partitions))
+	for _, p := range pm.Partitions {
+		bounds = append(bounds, p.Range.End)
+	}
+	for _, p := range old.Partitions {
+		bounds = append(bounds, p.Range.End)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	changes := make([]RangeChange, 0)
+	for i, end := range bounds {
+		start := bounds[(i+len(bounds)-1)%len(bounds)]
+		before, after := old.Owners(end).Replicas, pm.Owners(end).Replicas
+		if slices.Equal(before, after) {
+			continue
+		}
+		if n := len(changes); n > 0 && changes[n-1].Range.End == start &&
+			slices.Equal(changes[n-1].OldReplicas, before) && slices.Equal(changes[n-1].NewReplicas, after) {
+			changes[n-1].Range.End = end
+			continue
+		}
+		changes = append(changes, RangeChange{
+			Range:       TokenRange{Start: start, End: end},
+			OldReplicas: before,
+			NewReplicas: after,
+		})
+	}
+	return changes
+}
+
+// Encode serializes the map as JSON. Partitions are kept in token order, so
+// equal maps always encode to the same bytes.
+func (pm *PartitionMap) Encode() ([]byte, error) {
+	return json.Marshal(pm)
+}
+
+// DecodePartitionMap parses a map produced by Encode.
+func DecodePartitionMap(data []byte) (*PartitionMap, error) {
+	var pm PartitionMap
+	if err := json.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("failed to decode partition map: %w", err)
+	}
+	if !slices.IsSortedFunc(pm.Partitions, func(a, b Partition) int {
+		return cmp.Compare(a.Range.End, b.Range.End)
+	}) {
+		return nil, fmt.Errorf("failed to decode partition map: partitions not sorted by end token")
+	}
+	return &pm, nil
+}
+
+func (pm *PartitionMap) clone() *PartitionMap {
+	out := &PartitionMap{Epoch: pm.Epoch, Partitions: make([]Partition, len(pm.Partitions))}
+	for i, p := range pm.Partitions {
+		p.Replicas = slices.Clone(p.Replicas)
+		out.Partitions[i] = p
+	}
+	return out
+}
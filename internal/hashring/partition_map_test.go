@@ -0,0 +1,160 @@
+package hashring
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// newPartitionRing returns a consistent hash ring with replication factor 3
+// holding the given nodes, added in order.
+func newPartitionRing(t *testing.T, ids ...string) *HashRing {
+	t.Helper()
+	ring := InitHashRing(SetVirtualNodes(16), SetReplicationFactor(3))
+	for _, id := range ids {
+		if err := ring.AddNode(testNode{id: id, weight: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ring
+}
+
+func partitionMap(t *testing.T, ring *HashRing, prev *PartitionMap) *PartitionMap {
+	t.Helper()
+	pm, err := ring.PartitionMap(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pm
+}
+
+func TestPartitionMapEncodingIsDeterministic(t *testing.T) {
+	a := partitionMap(t, newPartitionRing(t, "node-0", "node-1", "node-2", "node-3"), nil)
+	b := partitionMap(t, newPartitionRing(t, "node-3", "node-1", "node-0", "node-2"), nil)
+
+	encA, err := a.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encB, err := b.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encA, encB) {
+		t.Fatalf("maps of the same ring encode differently:\n%s\n%s", encA, encB)
+	}
+
+	decoded, err := DecodePartitionMap(encA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, a) {
+		t.Fatalf("decoded map differs from the original")
+	}
+	reencoded, err := decoded.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reencoded, encA) {
+		t.Fatalf("re-encoding a decoded map changed its bytes")
+	}
+
+	slices.Reverse(decoded.Partitions)
+	unsorted, err := decoded.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodePartitionMap(unsorted); err == nil {
+		t.Fatal("decoding a map with unsorted partitions succeeded")
+	}
+}
+
+func TestPartitionMapEpochs(t *testing.T) {
+	ring := newPartitionRing(t, "node-0", "node-1", "node-2", "node-3")
+	first := partitionMap(t, ring, nil)
+	if first.Epoch != 1 {
+		t.Fatalf("first map has epoch %d, want 1", first.Epoch)
+	}
+
+	same := partitionMap(t, ring, first)
+	if !reflect.DeepEqual(same, first) {
+		t.Fatalf("unchanged ring moved the map from epoch %d to %d", first.Epoch, same.Epoch)
+	}
+
+	if err := ring.AddNode(testNode{id: "node-4", weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	grown := partitionMap(t, ring, same)
+	if grown.Epoch != 2 {
+		t.Fatalf("map after a join has epoch %d, want 2", grown.Epoch)
+	}
+	kept, bumped := 0, 0
+	for _, p := range grown.Partitions {
+		before := first.Owners(p.Range.End).Replicas
+		switch p.Epoch {
+		case 1:
+			kept++
+			if !slices.Equal(before, p.Replicas) {
+				t.Errorf("partition %s kept epoch 1 but its replicas changed from %v to %v", p.Range, before, p.Replicas)
+			}
+		case 2:
+			bumped++
+			if !slices.Contains(p.Replicas, "node-4") {
+				t.Errorf("partition %s moved to epoch 2 without gaining node-4: %v", p.Range, p.Replicas)
+			}
+		default:
+			t.Errorf("partition %s has epoch %d, want 1 or 2", p.Range, p.Epoch)
+		}
+	}
+	if kept == 0 || bumped == 0 {
+		t.Fatalf("join kept %d and bumped %d partitions, want some of each", kept, bumped)
+	}
+
+	if err := ring.RemoveNode(testNode{id: "node-4", weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if shrunk := partitionMap(t, ring, grown); shrunk.Epoch != 3 {
+		t.Fatalf("map after a leave has epoch %d, want 3", shrunk.Epoch)
+	}
+}
+
+func TestPartitionMapDiff(t *testing.T) {
+	ring := newPartitionRing(t, "node-0", "node-1", "node-2", "node-3")
+	before := partitionMap(t, ring, nil)
+	if changes := before.Diff(before); len(changes) != 0 {
+		t.Fatalf("diff of a map with itself has %d changes", len(changes))
+	}
+
+	if err := ring.AddNode(testNode{id: "node-4", weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	after := partitionMap(t, ring, before)
+	changes := after.Diff(before)
+	if len(changes) == 0 {
+		t.Fatal("diff after a join is empty")
+	}
+	for _, c := range changes {
+		if slices.Contains(c.OldReplicas, "node-4") || !slices.Contains(c.NewReplicas, "node-4") {
+			t.Errorf("change %s moves %v to %v, want node-4 to join", c.Range, c.OldReplicas, c.NewReplicas)
+		}
+	}
+
+	// Every token whose owners changed falls in exactly one reported range.
+	for _, key := range testKeys(5_000) {
+		token, err := ring.Token(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moved := !slices.Equal(before.Owners(token).Replicas, after.Owners(token).Replicas)
+		covering := 0
+		for _, c := range changes {
+			if c.Range.Contains(token) {
+				covering++
+			}
+		}
+		if moved && covering != 1 || !moved && covering != 0 {
+			t.Fatalf("token %d (moved %v) is covered by %d changes", token, moved, covering)
+		}
+	}
+}
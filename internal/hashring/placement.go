@@ -0,0 +1,103 @@
+package hashring
+
+// FailureDomain is the widest domain replicas of a key are spread across.
+type FailureDomain int
+
+const (
+	// DomainNone places replicas on the next distinct nodes on the ring.
+	DomainNone FailureDomain = iota
+	// DomainRack spreads replicas across racks.
+	DomainRack
+	// DomainZone spreads replicas across availability zones, then racks.
+	DomainZone
+	// DomainRegion spreads replicas across regions, then zones, then racks.
+	DomainRegion
+)
+
+// ILocatedNode is implemented by nodes that know which failure domains they
+// belong to. Nodes without a location are treated as sharing one domain.
+type ILocatedNode interface {
+	ICacheNode
+	Region() string
+	Zone() string
+	Rack() string
+}
+
+// SetPlacementPolicy makes GetNodesForKey spread replicas across the given
+// failure domain. With fewer domains than replicas, domains are reused in
+// ring order.
+func SetPlacementPolicy(domain FailureDomain) HashRingConfigFn {
+	return func(cfg *hashRingConfig) {
+		cfg.FailureDomain = domain
+	}
+}
+
+// domainKeys returns the node's domains from the configured level down to the
+// rack, coarsest first. Each key includes its parents, so equal rack names in
+// different zones are different racks.
+func domainKeys(node ICacheNode, domain FailureDomain) []string {
+	var region, zone, rack string
+	if ln, ok := node.(ILocatedNode); ok {
+		region, zone, rack = ln.Region(), ln.Zone(), ln.Rack()
+	}
+	zoneKey := region + "/" + zone
+	rackKey := zoneKey + "/" + rack
+	switch domain {
+	case DomainRegion:
+		return []string{region, zoneKey, rackKey}
+	case DomainZone:
+		return []string{zoneKey, rackKey}
+	case DomainRack:
+		return []string{rackKey}
+	default:
+		return nil
+	}
+}
+
+// spreadAcrossDomains picks count nodes from candidates, which are distinct
+// nodes in ring order. Each pick is the first candidate sharing the fewest
+// domains with those already picked, comparing the coarsest domain first, so
+// the result falls back to ring order when domains run out.
+func spreadAcrossDomains(candidates []ICacheNode, count int, domain FailureDomain) []ICacheNode {
+	keys := make([][]string, len(candidates))
+	for i, n := range candidates {
+		keys[i] = domainKeys(n, domain)
+	}
+	levels := len(domainKeys(nil, domain))
+	used := make([]map[string]int, levels)
+	for l := range used {
+		used[l] = make(map[string]int)
+	}
+
+	picked := make([]bool, len(candidates))
+	nodes := make([]ICacheNode, 0, count)
+	for len(nodes) < count && len(nodes) < len(candidates) {
+		best := -1
+		for i := range candidates {
+			if picked[i] {
+				continue
+			}
+			if best == -1 || fewerShared(keys[i], keys[best], used) {
+				best = i
+			}
+		}
+		picked[best] = true
+		nodes = append(nodes, candidates[best])
+		for l, k := range keys[best] {
+			used[l][k]++
+		}
+	}
+	return nodes
+}
+
+// fewerShared reports whether a overlaps less with the used domains than b,
+// comparing level by level from the coarsest.
+func fewerShared(a, b []string, used []map[string]int) bool {
+	for l := range used {
+		ua, ub := used[l][a[l]], used[l][b[l]]
+		if ua != ub {
+			return ua < ub
+		}
+	}
+	return false
+}
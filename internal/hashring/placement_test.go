@@ -0,0 +1,174 @@
+package hashring
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// locatedNode is a test node that knows its failure domains.
+type locatedNode struct {
+	testNode
+	region, zone, rack string
+}
+
+func (n locatedNode) Region() string { return n.region }
+func (n locatedNode) Zone() string   { return n.zone }
+func (n locatedNode) Rack() string   { return n.rack }
+
+func located(id, region, zone, rack string) ICacheNode {
+	return locatedNode{testNode: testNode{id: id, weight: 1}, region: region, zone: zone, rack: rack}
+}
+
+func TestSpreadAcrossDomains(t *testing.T) {
+	tests := []struct {
+		name       string
+		domain     FailureDomain
+		candidates []ICacheNode
+		count      int
+		want       []string
+	}{
+		{
+			name:   "distinct racks",
+			domain: DomainRack,
+			candidates: []ICacheNode{
+				located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r1"),
+				located("c", "eu", "z1", "r2"), located("d", "eu", "z1", "r3"),
+			},
+			count: 3,
+			want:  []string{"a", "c", "d"},
+		},
+		{
+			name:   "rack names are scoped to their zone",
+			domain: DomainRack,
+			candidates: []ICacheNode{
+				located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r1"),
+				located("c", "eu", "z2", "r1"),
+			},
+			count: 2,
+			want:  []string{"a", "c"},
+		},
+		{
+			name:   "distinct zones",
+			domain: DomainZone,
+			candidates: []ICacheNode{
+				located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r2"),
+				located("c", "eu", "z2", "r1"), located("d", "eu", "z3", "r1"),
+			},
+			count: 3,
+			want:  []string{"a", "c", "d"},
+		},
+		{
+			name:   "distinct regions, then zones",
+			domain: DomainRegion,
+			candidates: []ICacheNode{
+				located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r2"),
+				located("c", "eu", "z2", "r1"), located("d", "us", "z1", "r1"),
+			},
+			count: 3,
+			want:  []string{"a", "d", "c"},
+		},
+		{
+			name:   "fewer racks than replicas",
+			domain: DomainRack,
+			candidates: []ICacheNode{
+				located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r1"),
+				located("c", "eu", "z1", "r2"), located("d", "eu", "z1", "r2"),
+			},
+			count: 3,
+			want:  []string{"a", "c", "b"},
+		},
+		{
+			name:   "fewer zones than replicas prefers distinct racks",
+			domain: DomainZone,
+			candidates: []ICacheNode{
+				located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r1"),
+				located("c", "eu", "z1", "r2"), located("d", "eu", "z2", "r1"),
+			},
+			count: 3,
+			want:  []string{"a", "d", "c"},
+		},
+		{
+			name:   "unlocated nodes keep ring order",
+			domain: DomainZone,
+			candidates: []ICacheNode{
+				testNode{id: "a", weight: 1}, testNode{id: "b", weight: 1}, testNode{id: "c", weight: 1},
+			},
+			count: 2,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:       "fewer nodes than replicas",
+			domain:     DomainRack,
+			candidates: []ICacheNode{located("a", "eu", "z1", "r1"), located("b", "eu", "z1", "r2")},
+			count:      3,
+			want:       []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nodeIDs(spreadAcrossDomains(tt.candidates, tt.count, tt.domain))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlacementPolicySpreadsReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   FailureDomain
+		zones    int
+		distinct int
+	}{
+		{name: "enough zones", domain: DomainZone, zones: 3, distinct: 3},
+		{name: "fewer zones than replicas", domain: DomainZone, zones: 2, distinct: 2},
+		{name: "enough regions", domain: DomainRegion, zones: 3, distinct: 3},
+	}
+	const replicas = 3
+	keys := testKeys(2_000)
+	for _, algorithm := range algorithms {
+		for _, tt := range tests {
+			t.Run(string(algorithm)+"/"+tt.name, func(t *testing.T) {
+				p, err := NewPlacement(algorithm, SetVirtualNodes(128),
+					SetReplicationFactor(replicas), SetPlacementPolicy(tt.domain))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := range 9 {
+					zone := fmt.Sprintf("z%d", i%tt.zones)
+					// One region per zone, so DomainRegion spreads the same way.
+					node := located(fmt.Sprintf("node-%d", i), "region-"+zone, zone, fmt.Sprintf("r%d", i/tt.zones))
+					if err := p.AddNode(node); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for _, key := range keys {
+					nodes, err := p.GetNodesForKey(key)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(nodes) != replicas {
+						t.Fatalf("key %s got %d replicas, want %d", key, len(nodes), replicas)
+					}
+					zones := make(map[string]struct{})
+					for _, n := range nodes {
+						zones[n.(ILocatedNode).Zone()] = struct{}{}
+					}
+					if len(zones) != tt.distinct {
+						t.Fatalf("key %s replicas %v span %d zones, want %d", key, nodeIDs(nodes), len(zones), tt.distinct)
+					}
+				}
+			})
+		}
+	}
+}
+
+func nodeIDs(nodes []ICacheNode) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.GetIdentifier()
+	}
+	return ids
+}
@@ -0,0 +1,110 @@
+package hashring
+
+// Replica is a node a key is written to. When an owner is down a healthy node
+// further down the preference order stands in for it, and HintFor names the
+// owner the write is meant for, so it can be handed back later.
+type Replica struct {
+	Node    ICacheNode
+	HintFor string // Owner this node stands in for, empty if it is an owner
+}
+
+// PreferenceList is the order in which nodes should hold a key under a sloppy
+// quorum: up to ReplicationFactor replicas, then the remaining healthy nodes
+// as fallbacks to try if a replica does not answer.
+type PreferenceList struct {
+	Replicas  []Replica    // Healthy owners, with stand-ins in place of owners that are down
+	Fallbacks []ICacheNode // Further healthy nodes, in preference order
+}
+
+// buildPreferenceList turns every node, in preference order for a key, into a
+// preference list. The owners are picked from candidates as GetNodesForKey
+// would; owners isUp reports down are replaced by the first healthy
+// non-owners, in order. A nil isUp treats every node as up.
+func buildPreferenceList(candidates []ICacheNode, cfg *hashRingConfig, isUp func(id string) bool) (*PreferenceList, error) {
+	if isUp == nil {
+		isUp = func(string) bool { return true }
+	}
+	owners := pickReplicas(candidates, cfg)
+	isOwner := make(map[string]struct{}, len(owners))
+	for _, o := range owners {
+		isOwner[o.GetIdentifier()] = struct{}{}
+	}
+
+	pl := &PreferenceList{Fallbacks: make([]ICacheNode, 0)}
+	for _, c := range candidates {
+		if _, owner := isOwner[c.GetIdentifier()]; owner || !isUp(c.GetIdentifier()) {
+			continue
+		}
+		pl.Fallbacks = append(pl.Fallbacks, c)
+	}
+
+	pl.Replicas = make([]Replica, 0, len(owners))
+	for _, o := range owners {
+		if isUp(o.GetIdentifier()) {
+			pl.Replicas = append(pl.Replicas, Replica{Node: o})
+			continue
+		}
+		if len(pl.Fallbacks) == 0 {
+			continue
+		}
+		pl.Replicas = append(pl.Replicas, Replica{Node: pl.Fallbacks[0], HintFor: o.GetIdentifier()})
+		pl.Fallbacks = pl.Fallbacks[1:]
+	}
+	if len(pl.Replicas) == 0 {
+		return nil, ErrNoNodesAvailable
+	}
+	return pl, nil
+}
+
+// PreferenceList returns the replicas and fallbacks for key, skipping nodes
+// isUp reports down so writes stay available while owners fail.
+func (ring *HashRing) PreferenceList(key string, isUp func(id string) bool) (*PreferenceList, error) {
+	s := ring.snapshot()
+	if len(s.tokens) == 0 {
+		return nil, ErrNoNodesAvailable
+	}
+	h, err := ring.generateHash(key)
+	if err != nil {
+		return nil, err
+	}
+	return buildPreferenceList(distinctFrom(s.owners, s.search(h), len(s.nodes)), &ring.config, isUp)
+}
+
+// PreferenceList returns the replicas and fallbacks for key, skipping nodes
+// isUp reports down.
+func (r *Rendezvous) PreferenceList(key string, isUp func(id string) bool) (*PreferenceList, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates, err := r.ranked(key)
+	if err != nil {
+		return nil, err
+	}
+	return buildPreferenceList(candidates, &r.config, isUp)
+}
+
+// PreferenceList returns the replicas and fallbacks for key, skipping nodes
+// isUp reports down.
+func (m *MultiProbe) PreferenceList(key string, isUp func(id string) bool) (*PreferenceList, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, err := m.closest(key)
+	if err != nil {
+		return nil, err
+	}
+	return buildPreferenceList(distinctFrom(m.owners, idx, len(m.members.nodes)), &m.config, isUp)
+}
+
+// PreferenceList returns the replicas and fallbacks for key, skipping nodes
+// isUp reports down.
+func (j *Jump) PreferenceList(key string, isUp func(id string) bool) (*PreferenceList, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	b, err := j.bucket(key)
+	if err != nil {
+		return nil, err
+	}
+	return buildPreferenceList(distinctFrom(j.buckets, b, len(j.members.nodes)), &j.config, isUp)
+}
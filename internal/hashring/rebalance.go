@@ -0,0 +1,167 @@
+package hashring
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Transfer copies one token range from a node that holds it to a node that
+// is about to own it.
+type Transfer struct {
+	Range          TokenRange `json:"range" yaml:"range"`                   // Tokens to copy
+	Source         string     `json:"source" yaml:"source"`                 // Node streaming the range
+	Destination    string     `json:"destination" yaml:"destination"`       // Node receiving the range
+	EstimatedBytes int64      `json:"estimatedBytes" yaml:"estimatedBytes"` // Expected size of the transfer
+}
+
+// RebalancePlan lists the transfers needed to move from one partition map to
+// another.
+type RebalancePlan struct {
+	FromEpoch      uint64     `json:"fromEpoch" yaml:"fromEpoch"`           // Epoch of the map being replaced
+	ToEpoch        uint64     `json:"toEpoch" yaml:"toEpoch"`               // Epoch of the map being applied
+	Transfers      []Transfer `json:"transfers" yaml:"transfers"`           // Transfers in token order
+	Unsourced      []Transfer `json:"unsourced" yaml:"unsourced"`           // Transfers whose old replicas all left the ring, with no Source
+	EstimatedBytes int64      `json:"estimatedBytes" yaml:"estimatedBytes"` // Sum of all transfer estimates
+}
+
+// RangeSizer estimates how many bytes a token range holds.
+type RangeSizer func(r TokenRange) int64
+
+// UniformSizer assumes keys are spread evenly over the hash space, given that
+// a node holds bytes for the share of the ring it replicates.
+func UniformSizer(bytes int64, share float64) RangeSizer {
+	return func(r TokenRange) int64 {
+		if share <= 0 {
+			return 0
+		}
+		return int64(float64(bytes) / share * r.Share())
+	}
+}
+
+// Share returns the fraction of the hash space the range covers.
+func (r TokenRange) Share() float64 {
+	if r.Start == r.End {
+		return 1
+	}
+	// Unsigned subtraction wraps, which is exactly the arc length.
+	return float64(r.End-r.Start) / math.MaxUint64
+}
+
+// Share returns the fraction of the hash space nodeID replicates.
+func (pm *PartitionMap) Share(nodeID string) float64 {
+	share := 0.0
+	for _, p := range pm.Partitions {
+		if slices.Contains(p.Replicas, nodeID) {
+			share += p.Range.Share()
+		}
+	}
+	return share
+}
+
+// PlanRebalance computes the transfers that bring every new replica of a range
+// up to date. Ranges that only changed replica order need no data and are
+// left out. Each transfer is sourced from an old replica, preferring ones that
+// stay replicas and then the least loaded, and neighbouring transfers between
+// the same pair of nodes are merged to keep the number of moving ranges low.
+// Old replicas that are no longer in next cannot be streamed from; if none
+// remain, the transfer is listed in Unsourced instead.
+func PlanRebalance(old, next *PartitionMap, size RangeSizer) *RebalancePlan {
+	plan := &RebalancePlan{
+		FromEpoch: old.Epoch,
+		ToEpoch:   next.Epoch,
+		Transfers: make([]Transfer, 0),
+		Unsourced: make([]Transfer, 0),
+	}
+	load := make(map[string]int64)
+	members := next.members()
+
+	for _, change := range next.Diff(old) {
+		bytes := size(change.Range)
+		for _, dest := range change.NewReplicas {
+			if slices.Contains(change.OldReplicas, dest) {
+				continue
+			}
+			if len(change.OldReplicas) == 0 {
+				continue // range had no replicas before; nothing to copy
+			}
+			source := pickSource(change, members, load)
+			if source == "" {
+				plan.Unsourced = appendTransfer(plan.Unsourced, Transfer{
+					Range:          change.Range,
+					Destination:    dest,
+					EstimatedBytes: bytes,
+				})
+				continue
+			}
+			load[source] += bytes
+			plan.EstimatedBytes += bytes
+			plan.Transfers = appendTransfer(plan.Transfers, Transfer{
+				Range:          change.Range,
+				Source:         source,
+				Destination:    dest,
+				EstimatedBytes: bytes,
+			})
+		}
+	}
+	return plan
+}
+
+// appendTransfer adds t to transfers, extending the last one instead if it
+// ends where t starts and runs between the same nodes.
+func appendTransfer(transfers []Transfer, t Transfer) []Transfer {
+	if n := len(transfers); n > 0 {
+		last := &transfers[n-1]
+		if last.Range.End == t.Range.Start && last.Source == t.Source && last.Destination == t.Destination {
+			last.Range.End = t.Range.End
+			last.EstimatedBytes += t.EstimatedBytes
+			return transfers
+		}
+	}
+	return append(transfers, t)
+}
+
+// members returns the IDs of the nodes replicating any partition of pm.
+func (pm *PartitionMap) members() map[string]struct{} {
+	ids := make(map[string]struct{})
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// pickSource chooses the old replica to stream a changed range from, among
+// those still in members. It returns "" if none is.
+func pickSource(change RangeChange, members map[string]struct{}, load map[string]int64) string {
+	best := ""
+	bestStays := false
+	for _, candidate := range change.OldReplicas {
+		if _, ok := members[candidate]; !ok {
+			continue
+		}
+		stays := slices.Contains(change.NewReplicas, candidate)
+		switch {
+		case best == "",
+			stays && !bestStays,
+			stays == bestStays && load[candidate] < load[best]:
+			best, bestStays = candidate, stays
+		}
+	}
+	return best
+}
+
+// String renders the plan for operators to review before applying it.
+func (p *RebalancePlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rebalance epoch %d → %d: %d transfers, ~%d bytes\n", p.FromEpoch, p.ToEpoch, len(p.Transfers), p.EstimatedBytes)
+	for _, t := range p.Transfers {
+		fmt.Fprintf(&b, "  %s %s → %s (~%d bytes)\n", t.Range, t.Source, t.Destination, t.EstimatedBytes)
+	}
+	for _, t := range p.Unsourced {
+		fmt.Fprintf(&b, "  %s no source left → %s (~%d bytes)\n", t.Range, t.Destination, t.EstimatedBytes)
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package hashring
+
+import (
+	"slices"
+	"testing"
+)
+
+// sizeByShare sizes a range as if the whole ring held 1 GB.
+func sizeByShare(r TokenRange) int64 {
+	return int64(r.Share() * (1 << 30))
+}
+
+// checkPlan fails unless, for sampled keys, the plan copies a key's range to
+// exactly the replicas it gains, from an old replica that is still on the
+// ring and that stays a replica whenever one does.
+func checkPlan(t *testing.T, ring *HashRing, old, next *PartitionMap, plan *RebalancePlan) {
+	t.Helper()
+	if len(plan.Unsourced) != 0 {
+		t.Fatalf("plan has %d unsourced transfers, want none", len(plan.Unsourced))
+	}
+	members := next.members()
+	for _, key := range testKeys(5_000) {
+		token, err := ring.Token(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, after := old.Owners(token).Replicas, next.Owners(token).Replicas
+		gained := make([]string, 0)
+		for _, id := range after {
+			if !slices.Contains(before, id) {
+				gained = append(gained, id)
+			}
+		}
+		got := make([]string, 0)
+		for _, tr := range plan.Transfers {
+			if !tr.Range.Contains(token) {
+				continue
+			}
+			got = append(got, tr.Destination)
+			if !slices.Contains(before, tr.Source) {
+				t.Errorf("token %d is streamed from %s, which was not a replica of %v", token, tr.Source, before)
+			}
+			if _, ok := members[tr.Source]; !ok {
+				t.Errorf("token %d is streamed from %s, which left the ring", token, tr.Source)
+			}
+			stays := slices.ContainsFunc(before, func(id string) bool { return slices.Contains(after, id) })
+			if stays && !slices.Contains(after, tr.Source) {
+				t.Errorf("token %d is streamed from %s, which stops replicating it, while %v keep it", token, tr.Source, after)
+			}
+		}
+		slices.Sort(gained)
+		slices.Sort(got)
+		if !slices.Equal(got, gained) {
+			t.Fatalf("token %d moves from %v to %v, but the plan copies it to %v", token, before, after, got)
+		}
+	}
+}
+
+func TestPlanRebalanceOnJoin(t *testing.T) {
+	ring := newPartitionRing(t, "node-0", "node-1", "node-2", "node-3")
+	old := partitionMap(t, ring, nil)
+	if err := ring.AddNode(testNode{id: "node-4", weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	next := partitionMap(t, ring, old)
+
+	plan := PlanRebalance(old, next, sizeByShare)
+	if plan.FromEpoch != old.Epoch || plan.ToEpoch != next.Epoch {
+		t.Fatalf("plan is for epochs %d → %d, want %d → %d", plan.FromEpoch, plan.ToEpoch, old.Epoch, next.Epoch)
+	}
+	if len(plan.Transfers) == 0 {
+		t.Fatal("join planned no transfers")
+	}
+	for _, tr := range plan.Transfers {
+		if tr.Destination != "node-4" {
+			t.Errorf("join streams %s to %s, want only node-4 to receive data", tr.Range, tr.Destination)
+		}
+	}
+	checkPlan(t, ring, old, next, plan)
+}
+
+func TestPlanRebalanceOnLeave(t *testing.T) {
+	ring := newPartitionRing(t, "node-0", "node-1", "node-2", "node-3", "node-4")
+	old := partitionMap(t, ring, nil)
+	if err := ring.RemoveNode(testNode{id: "node-1", weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	next := partitionMap(t, ring, old)
+
+	plan := PlanRebalance(old, next, sizeByShare)
+	if len(plan.Transfers) == 0 {
+		t.Fatal("leave planned no transfers")
+	}
+	checkPlan(t, ring, old, next, plan)
+}
+
+func TestPlanRebalanceUnchangedRing(t *testing.T) {
+	ring := newPartitionRing(t, "node-0", "node-1", "node-2", "node-3")
+	old := partitionMap(t, ring, nil)
+	plan := PlanRebalance(old, partitionMap(t, ring, old), sizeByShare)
+	if len(plan.Transfers) != 0 || plan.EstimatedBytes != 0 {
+		t.Fatalf("unchanged ring planned %d transfers of %d bytes", len(plan.Transfers), plan.EstimatedBytes)
+	}
+}
+
+func TestPlanRebalanceFlagsRangesWithoutSource(t *testing.T) {
+	ring := InitHashRing(SetVirtualNodes(16), SetReplicationFactor(1))
+	for _, id := range []string{"node-0", "node-1", "node-2"} {
+		if err := ring.AddNode(testNode{id: id, weight: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := partitionMap(t, ring, nil)
+	if err := ring.RemoveNode(testNode{id: "node-1", weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	next := partitionMap(t, ring, old)
+
+	plan := PlanRebalance(old, next, sizeByShare)
+	if len(plan.Transfers) != 0 {
+		t.Fatalf("plan streams %d ranges from a node that left: %v", len(plan.Transfers), plan.Transfers)
+	}
+	if len(plan.Unsourced) == 0 {
+		t.Fatal("ranges held only by the departed node are not flagged")
+	}
+	share := 0.0
+	for _, tr := range plan.Unsourced {
+		if tr.Source != "" {
+			t.Errorf("unsourced transfer %s names source %s", tr.Range, tr.Source)
+		}
+		share += tr.Range.Share()
+	}
+	if want := old.Share("node-1"); share < want-1e-9 || share > want+1e-9 {
+		t.Fatalf("unsourced ranges cover %.4f of the ring, want node-1's %.4f", share, want)
+	}
+}
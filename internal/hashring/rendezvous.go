@@ -0,0 +1,145 @@
+package hashring
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Rendezvous places keys with highest random weight hashing: every node gets
+// a score per key and the highest scores win. Adding or removing a node only
+// moves the keys it wins or held, and no virtual nodes are needed, at the cost
+// of lookups that are linear in the number of nodes.
+type Rendezvous struct {
+	mu      sync.RWMutex
+	config  hashRingConfig
+	members memberSet
+}
+
+func NewRendezvous(opts ...HashRingConfigFn) *Rendezvous {
+	return &Rendezvous{
+		config:  *newHashRingConfig(opts),
+		members: newMemberSet(),
+	}
+}
+
+func (r *Rendezvous) AddNode(node ICacheNode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.members.add(node)
+	return err
+}
+
+func (r *Rendezvous) RemoveNode(node ICacheNode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.members.remove(node.GetIdentifier())
+}
+
+// SetNodeWeight changes a node's weight. Only keys whose winner changes move.
+func (r *Rendezvous) SetNodeWeight(id string, weight int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.members.reweight(id, weight)
+	return err
+}
+
+// UpdateNode replaces a node with a newer copy of it, reweighting it if its
+// weight changed.
+func (r *Rendezvous) UpdateNode(node ICacheNode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _, err := r.members.replace(node)
+	return err
+}
+
+func (r *Rendezvous) GetNode(id string) (ICacheNode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.members.get(id)
+}
+
+func (r *Rendezvous) GetAllNodes() ([]ICacheNode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.members.all()
+}
+
+func (r *Rendezvous) GetPrimaryNode(key string) (ICacheNode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best ICacheNode
+	bestScore := math.Inf(-1)
+	for id, n := range r.members.nodes {
+		s, err := r.score(key, id)
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || s > bestScore || (s == bestScore && id < best.GetIdentifier()) {
+			best, bestScore = n, s
+		}
+	}
+	if best == nil {
+		return nil, ErrNoNodesAvailable
+	}
+	return best, nil
+}
+
+func (r *Rendezvous) GetNodesForKey(key string) ([]ICacheNode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates, err := r.ranked(key)
+	if err != nil {
+		return nil, err
+	}
+	return pickReplicas(candidates, &r.config), nil
+}
+
+// ranked returns every node ordered by its score for key, highest first.
+func (r *Rendezvous) ranked(key string) ([]ICacheNode, error) {
+	if len(r.members.nodes) == 0 {
+		return nil, ErrNoNodesAvailable
+	}
+	type scored struct {
+		node  ICacheNode
+		score float64
+	}
+	ranked := make([]scored, 0, len(r.members.nodes))
+	for id, n := range r.members.nodes {
+		s, err := r.score(key, id)
+		if err != nil {
+			return nil, err
+		}
+		ranked = append(ranked, scored{node: n, score: s})
+	}
+	slices.SortFunc(ranked, func(a, b scored) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		default:
+			return strings.Compare(a.node.GetIdentifier(), b.node.GetIdentifier())
+		}
+	})
+	candidates := make([]ICacheNode, len(ranked))
+	for i, s := range ranked {
+		candidates[i] = s.node
+	}
+	return candidates, nil
+}
+
+// score is the weighted rendezvous score of a node for key, -w/ln(u) with u
+// the pair's hash mapped into (0, 1). It makes each node win a share of keys
+// proportional to its weight.
+func (r *Rendezvous) score(key, id string) (float64, error) {
+	h, err := r.config.hash(id + "\x00" + key)
+	if err != nil {
+		return 0, ErrHashingKey
+	}
+	u := (float64(h>>11) + 0.5) / (1 << 53)
+	return -float64(r.members.weights[id]) / math.Log(u), nil
+}
@@ -4,126 +4,358 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"maps"
+	"math/bits"
+	"slices"
 	"sync"
 )
 
-// MerkleNode represents a node (internal or leaf) in the Merkle tree.
-type MerkleNode struct {
-	Hash  string
-	Left  *MerkleNode
-	Right *MerkleNode
-	// For a leaf, KeyRange is non-empty and covers bucket/partition keys.
-	KeyRange []string
-}
+var (
+	ErrShapeMismatch = errors.New("cannot diff trees over different ranges or depths")
+	ErrInvalidNode   = errors.New("node index out of range")
+)
+
+// Digest is the hash of a key's value, as stored in a leaf.
+type Digest = [sha256.Size]byte
 
-// Tree manages the tree for a partition or node.
+// Tree is a Merkle tree over the arc (Start, End] of the 64-bit hash space,
+// the whole space when Start equals End. The arc is split into 2^Depth equal
+// buckets, one leaf each, so the shape of the tree never depends on which keys
+// exist and changing a key rehashes only its leaf and the path from that leaf
+// to the root. A leaf's hash is the XOR of the hashes of its entries, so it is
+// updated in constant time whatever the number of keys in the leaf.
 type Tree struct {
-	BucketSize int // Number of keys per leaf node
-	Root       *MerkleNode
-	mu         sync.RWMutex
+	Start uint64 // Exclusive lower bound of the positions covered
+	End   uint64 // Inclusive upper bound of the positions covered
+	Depth int    // Levels below the root; the tree has 2^Depth leaves
+
+	mu sync.RWMutex
+	// nodes is the tree in heap order: the root is nodes[1], the children of
+	// nodes[i] are nodes[2i] and nodes[2i+1], and leaves start at 1<<Depth.
+	nodes  []Digest
+	leaves []map[string]Digest // key → value digest, per leaf
+}
+
+// NewTree creates an empty tree over the whole hash space with 2^depth leaves.
+func NewTree(depth int) *Tree {
+	return NewRangeTree(0, 0, depth)
 }
 
-// NewTree creates a MerkleTree with given bucket size.
-func NewTree(bucketSize int) *Tree {
+// NewRangeTree creates an empty tree over the positions (start, end] with
+// 2^depth leaves. Replicas of a range build their trees with the same bounds,
+// so the trees can be compared.
+func NewRangeTree(start, end uint64, depth int) *Tree {
 	return &Tree{
-		BucketSize: bucketSize,
+		Start:  start,
+		End:    end,
+		Depth:  depth,
+		nodes:  make([]Digest, 2<<depth),
+		leaves: make([]map[string]Digest, 1<<depth),
 	}
 }
 
-// Build builds/rebuilds the Merkle tree given a key-value map.
-// keys must be sorted before calling!
-func (t *Tree) Build(sortedKeys []string, kvs map[string][]byte) {
+// Update records the digest of key, which sits at position pos of the hash
+// space.
+func (t *Tree) Update(pos uint64, key string, digest Digest) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	leaves := t.buildLeaves(sortedKeys, kvs)
-	t.Root = buildMerkle(leaves)
-}
-
-// buildLeaves returns a slice of MerkleNodes (leaves).
-func (t *Tree) buildLeaves(keys []string, kvs map[string][]byte) []*MerkleNode {
-	var leaves []*MerkleNode
-	for i := 0; i < len(keys); i += t.BucketSize {
-		end := min(i+t.BucketSize, len(keys))
-		bucket := keys[i:end]
-		data := ""
-		for _, k := range bucket {
-			data += k + ":" + hex.EncodeToString(kvs[k])
-		}
-		h := sha256.Sum256([]byte(data))
-		leaves = append(leaves, &MerkleNode{
-			Hash:     hex.EncodeToString(h[:]),
-			KeyRange: bucket,
-		})
-	}
-	return leaves
-}
-
-// buildMerkle recursively builds the full tree from leaves.
-func buildMerkle(nodes []*MerkleNode) *MerkleNode {
-	if len(nodes) == 0 {
-		return nil
-	}
-	if len(nodes) == 1 {
-		return nodes[0]
-	}
-	var parents []*MerkleNode
-	for i := 0; i < len(nodes); i += 2 {
-		if i+1 < len(nodes) {
-			data := nodes[i].Hash + nodes[i+1].Hash
-			h := sha256.Sum256([]byte(data))
-			parents = append(parents, &MerkleNode{
-				Hash:  hex.EncodeToString(h[:]),
-				Left:  nodes[i],
-				Right: nodes[i+1],
-			})
-		} else {
-			parents = append(parents, nodes[i])
-		}
+
+	leaf := t.leafIndex(pos)
+	if t.leaves[leaf] == nil {
+		t.leaves[leaf] = make(map[string]Digest)
+	}
+	old, ok := t.leaves[leaf][key]
+	if ok && old == digest {
+		return
+	}
+	if ok {
+		t.toggle(leaf, key, old)
+	}
+	t.leaves[leaf][key] = digest
+	t.toggle(leaf, key, digest)
+	t.rehashPath(leaf)
+}
+
+// Remove forgets key, which sits at position pos of the hash space.
+func (t *Tree) Remove(pos uint64, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	leaf := t.leafIndex(pos)
+	old, ok := t.leaves[leaf][key]
+	if !ok {
+		return
+	}
+	delete(t.leaves[leaf], key)
+	t.toggle(leaf, key, old)
+	t.rehashPath(leaf)
+}
+
+// leafIndex maps a position to its bucket by its offset into the range.
+// Unsigned arithmetic wraps, which handles ranges crossing zero.
+func (t *Tree) leafIndex(pos uint64) int {
+	if t.Depth == 0 {
+		return 0
+	}
+	offset := pos - t.Start - 1
+	width := t.End - t.Start
+	if width == 0 {
+		// Whole hash space: the top Depth bits pick the bucket.
+		return int(offset >> (64 - t.Depth))
 	}
-	return buildMerkle(parents)
+	// offset < width, so offset × leaves / width fits and is below leaves.
+	hi, lo := bits.Mul64(offset, uint64(len(t.leaves)))
+	leaf, _ := bits.Div64(hi, lo, width)
+	return int(leaf)
+}
+
+// toggle adds the entry key → digest to a leaf's hash, or takes it out if it
+// is already in. An empty leaf hashes to zero.
+func (t *Tree) toggle(leaf int, key string, digest Digest) {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(digest[:])
+	var entry Digest
+	h.Sum(entry[:0])
+
+	sum := &t.nodes[len(t.leaves)+leaf]
+	for i := range sum {
+		sum[i] ^= entry[i]
+	}
+}
+
+// rehashPath recomputes every node on the path from a leaf to the root.
+func (t *Tree) rehashPath(leaf int) {
+	i := len(t.leaves) + leaf
+	for i > 1 {
+		i /= 2
+		t.nodes[i] = hashChildren(t.nodes[2*i], t.nodes[2*i+1])
+	}
+}
+
+// hashChildren hashes two child nodes into their parent. Parents of two empty
+// children stay empty, so an empty tree has an all-zero root.
+func hashChildren(left, right Digest) Digest {
+	if left == (Digest{}) && right == (Digest{}) {
+		return Digest{}
+	}
+	return sha256.Sum256(append(left[:], right[:]...))
 }
 
 // RootHash returns the hex hash for the tree root.
 func (t *Tree) RootHash() string {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	if t.Root == nil {
-		return ""
-	}
-	return t.Root.Hash
+	return hex.EncodeToString(t.nodes[1][:])
 }
 
-// Diff returns the list of KeyRanges in leaves that differ between this and another MerkleTree.
-// Both trees must have been built from same (sorted) set of keys and same bucket size.
-func (t *Tree) Diff(other *Tree) ([][]string, error) {
+// KeyDiff is how the keys of one tree differ from those of another.
+type KeyDiff struct {
+	Missing []string `json:"missing"` // Keys only the other tree holds
+	Extra   []string `json:"extra"`   // Keys only this tree holds
+	Changed []string `json:"changed"` // Keys both trees hold with different digests
+}
+
+// Empty reports whether the trees hold the same keys with the same digests.
+func (d KeyDiff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the tree with another over the same range and depth. Since
+// both trees bucket keys by position alone, a key one replica lacks lands in
+// the same leaf on both sides, so only subtrees whose hashes differ are
+// visited and every missing, extra or changed key is found. Each list is
+// sorted.
+func (t *Tree) Diff(other *Tree) (KeyDiff, error) {
+	if t.Start != other.Start || t.End != other.End || t.Depth != other.Depth {
+		return KeyDiff{}, ErrShapeMismatch
+	}
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	if t.Root == nil || other.Root == nil {
-		return nil, errors.New("cannot diff: tree(s) not built")
+	if t != other {
+		other.mu.RLock()
+		defer other.mu.RUnlock()
 	}
-	diffs := make([][]string, 0)
-	diffHelper(t.Root, other.Root, &diffs)
-	return diffs, nil
+
+	d := KeyDiff{Missing: make([]string, 0), Extra: make([]string, 0), Changed: make([]string, 0)}
+	t.diffHelper(other, 1, &d)
+	d.sort()
+	return d, nil
 }
 
-// diffHelper recursively compares two trees, collecting differing leaf key ranges.
-func diffHelper(a, b *MerkleNode, diffs *[][]string) {
-	if a == nil || b == nil {
+// diffHelper descends from node i into the subtrees whose hashes differ,
+// comparing the keys of differing leaves.
+func (t *Tree) diffHelper(other *Tree, i int, d *KeyDiff) {
+	if t.nodes[i] == other.nodes[i] {
 		return
 	}
-	if a.Hash == b.Hash {
+	if i >= len(t.leaves) {
+		leaf := i - len(t.leaves)
+		d.add(t.leaves[leaf], other.leaves[leaf])
 		return
 	}
-	// If both are leaves, collect their key range.
-	if a.Left == nil && a.Right == nil && b.Left == nil && b.Right == nil {
-		*diffs = append(*diffs, a.KeyRange)
-		return
+	t.diffHelper(other, 2*i, d)
+	t.diffHelper(other, 2*i+1, d)
+}
+
+// add records how the keys of a leaf differ from those of the same leaf in
+// the other tree.
+func (d *KeyDiff) add(local, remote map[string]Digest) {
+	for k, digest := range local {
+		other, ok := remote[k]
+		switch {
+		case !ok:
+			d.Extra = append(d.Extra, k)
+		case other != digest:
+			d.Changed = append(d.Changed, k)
+		}
 	}
-	// Try to compare children; handle imbalanced trees gracefully.
-	if a.Left != nil && b.Left != nil {
-		diffHelper(a.Left, b.Left, diffs)
+	for k := range remote {
+		if _, ok := local[k]; !ok {
+			d.Missing = append(d.Missing, k)
+		}
 	}
-	if a.Right != nil && b.Right != nil {
-		diffHelper(a.Right, b.Right, diffs)
+}
+
+func (d *KeyDiff) sort() {
+	slices.Sort(d.Missing)
+	slices.Sort(d.Extra)
+	slices.Sort(d.Changed)
+}
+
+// Leaves returns the number of leaves, 2^Depth.
+func (t *Tree) Leaves() int {
+	return len(t.leaves)
+}
+
+// Hashes returns the hex hashes of the nodes at the given heap indices, where
+// the root is 1 and the children of i are 2i and 2i+1.
+func (t *Tree) Hashes(indices []int) ([]string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	hashes := make([]string, len(indices))
+	for i, idx := range indices {
+		if idx < 1 || idx >= len(t.nodes) {
+			return nil, fmt.Errorf("%w: %d", ErrInvalidNode, idx)
+		}
+		hashes[i] = hex.EncodeToString(t.nodes[idx][:])
+	}
+	return hashes, nil
+}
+
+// LeafRange returns the positions (start, end] a leaf covers.
+func (t *Tree) LeafRange(leaf int) (start, end uint64) {
+	return t.Start + t.leafOffset(leaf), t.Start + t.leafOffset(leaf+1)
+}
+
+// leafOffset returns the first offset into the range that maps to leaf, the
+// inverse of leafIndex. For leaf == Leaves() it is the width of the range.
+func (t *Tree) leafOffset(leaf int) uint64 {
+	width := t.End - t.Start
+	if width == 0 {
+		// Whole hash space; the last leaf's end wraps to zero offset.
+		return uint64(leaf) << (64 - t.Depth)
+	}
+	// ceil(leaf × width / leaves)
+	hi, lo := bits.Mul64(uint64(leaf), width)
+	q, r := bits.Div64(hi, lo, uint64(len(t.leaves)))
+	if r > 0 {
+		q++
+	}
+	return q
+}
+
+// FetchFunc returns the hex hashes of the nodes of a remote tree at the given
+// heap indices.
+type FetchFunc func(indices []int) ([]string, error)
+
+// DiffRemote compares the tree with a remote tree of the same shape, one level
+// at a time, fetching only the children of nodes whose hashes differ. It
+// returns the indices of the leaves that differ.
+func (t *Tree) DiffRemote(fetch FetchFunc) ([]int, error) {
+	frontier := []int{1}
+	for len(frontier) > 0 {
+		remote, err := fetch(frontier)
+		if err != nil {
+			return nil, err
+		}
+		local, err := t.Hashes(frontier)
+		if err != nil {
+			return nil, err
+		}
+		if len(remote) != len(frontier) {
+			return nil, fmt.Errorf("remote returned %d hashes for %d nodes", len(remote), len(frontier))
+		}
+
+		differing := make([]int, 0)
+		for i, idx := range frontier {
+			if local[i] != remote[i] {
+				differing = append(differing, idx)
+			}
+		}
+		if len(differing) == 0 || differing[0] >= len(t.leaves) {
+			// Every node of a frontier is on the same level.
+			leaves := make([]int, len(differing))
+			for i, idx := range differing {
+				leaves[i] = idx - len(t.leaves)
+			}
+			return leaves, nil
+		}
+		frontier = make([]int, 0, 2*len(differing))
+		for _, idx := range differing {
+			frontier = append(frontier, 2*idx, 2*idx+1)
+		}
+	}
+	return []int{}, nil
+}
+
+// LeafDigests returns the key digests of the given leaves.
+func (t *Tree) LeafDigests(leaves []int) ([]map[string]Digest, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make([]map[string]Digest, len(leaves))
+	for i, leaf := range leaves {
+		if leaf < 0 || leaf >= len(t.leaves) {
+			return nil, fmt.Errorf("%w: leaf %d", ErrInvalidNode, leaf)
+		}
+		out[i] = maps.Clone(t.leaves[leaf])
+		if out[i] == nil {
+			out[i] = make(map[string]Digest)
+		}
+	}
+	return out, nil
+}
+
+// LeafFetchFunc returns the key digests of the given leaves of a remote tree.
+type LeafFetchFunc func(leaves []int) ([]map[string]Digest, error)
+
+// DiffRemoteKeys compares the tree with a remote tree of the same shape as
+// DiffRemote does, then fetches the key digests of the differing leaves only,
+// so it finds every missing, extra or changed key without either side
+// sending its whole key set. Each list is sorted.
+func (t *Tree) DiffRemoteKeys(hashes FetchFunc, leaves LeafFetchFunc) (KeyDiff, error) {
+	d := KeyDiff{Missing: make([]string, 0), Extra: make([]string, 0), Changed: make([]string, 0)}
+	differing, err := t.DiffRemote(hashes)
+	if err != nil || len(differing) == 0 {
+		return d, err
+	}
+	remote, err := leaves(differing)
+	if err != nil {
+		return KeyDiff{}, err
+	}
+	if len(remote) != len(differing) {
+		return KeyDiff{}, fmt.Errorf("remote returned %d leaves for %d requested", len(remote), len(differing))
+	}
+
+	t.mu.RLock()
+	for i, leaf := range differing {
+		d.add(t.leaves[leaf], remote[i])
 	}
+	t.mu.RUnlock()
+	d.sort()
+	return d, nil
 }
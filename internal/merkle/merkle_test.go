@@ -0,0 +1,209 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// testEntry is a key, its position in the hash space, and a value digest.
+type testEntry struct {
+	pos    uint64
+	key    string
+	digest Digest
+}
+
+func newEntry(key, value string) testEntry {
+	sum := sha256.Sum256([]byte(key))
+	return testEntry{pos: binary.BigEndian.Uint64(sum[:8]), key: key, digest: sha256.Sum256([]byte(value))}
+}
+
+func testEntries(count int) []testEntry {
+	entries := make([]testEntry, count)
+	for i := range entries {
+		entries[i] = newEntry(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i))
+	}
+	return entries
+}
+
+func fill(t *Tree, entries []testEntry) *Tree {
+	for _, e := range entries {
+		t.Update(e.pos, e.key, e.digest)
+	}
+	return t
+}
+
+func TestRootIndependentOfOrder(t *testing.T) {
+	entries := testEntries(5000)
+	want := fill(NewTree(8), entries).RootHash()
+
+	shuffled := append([]testEntry(nil), entries...)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	if got := fill(NewTree(8), shuffled).RootHash(); got != want {
+		t.Errorf("root after shuffled updates is %s, want %s", got, want)
+	}
+}
+
+func TestUpdateAndRemoveRestoreRoot(t *testing.T) {
+	entries := testEntries(1000)
+	tree := fill(NewTree(6), entries[:500])
+	want := tree.RootHash()
+
+	fill(tree, entries[500:])
+	changed := newEntry(entries[10].key, "changed")
+	tree.Update(changed.pos, changed.key, changed.digest)
+	if tree.RootHash() == want {
+		t.Fatal("root did not change after adding and changing keys")
+	}
+	tree.Update(entries[10].pos, entries[10].key, entries[10].digest)
+	for _, e := range entries[500:] {
+		tree.Remove(e.pos, e.key)
+	}
+	if got := tree.RootHash(); got != want {
+		t.Errorf("root after undoing changes is %s, want %s", got, want)
+	}
+
+	for _, e := range entries[:500] {
+		tree.Remove(e.pos, e.key)
+	}
+	if got, empty := tree.RootHash(), NewTree(6).RootHash(); got != empty {
+		t.Errorf("root of emptied tree is %s, want %s", got, empty)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	const start, end = 1 << 62, 1 << 63
+	inRange := make([]testEntry, 0)
+	for _, e := range testEntries(8000) {
+		if e.pos > start && e.pos <= end {
+			inRange = append(inRange, e)
+		}
+	}
+	tree := fill(NewRangeTree(start, end, 7), inRange)
+	restored, err := FromSnapshot(tree.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.RootHash() != tree.RootHash() {
+		t.Errorf("restored root %s, want %s", restored.RootHash(), tree.RootHash())
+	}
+	d, err := restored.Diff(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Errorf("restored tree differs: %+v", d)
+	}
+}
+
+// BenchmarkTreeUpdate changes one key of trees of growing size. The time per
+// update stays flat, since only the key's leaf and its path are rehashed.
+func BenchmarkTreeUpdate(b *testing.B) {
+	for _, size := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			entries := testEntries(size)
+			tree := fill(NewTree(10), entries)
+			digests := [2]Digest{sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b"))}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e := entries[i%len(entries)]
+				tree.Update(e.pos, e.key, digests[i%2])
+			}
+		})
+	}
+}
+
+// divergedReplicas builds two trees from a shared set of keys, then drops,
+// adds and changes random keys on each side. It returns the trees and the
+// diff of a against b that the changes should produce.
+func divergedReplicas(rng *rand.Rand, depth, keys, changes int) (*Tree, *Tree, KeyDiff) {
+	a, b := make(map[string]testEntry), make(map[string]testEntry)
+	for _, e := range testEntries(keys) {
+		a[e.key], b[e.key] = e, e
+	}
+	for i := range changes {
+		side := a
+		if rng.IntN(2) == 0 {
+			side = b
+		}
+		key := fmt.Sprintf("key-%d", rng.IntN(keys+changes))
+		switch rng.IntN(3) {
+		case 0:
+			delete(side, key)
+		default:
+			side[key] = newEntry(key, fmt.Sprintf("diverged-%d", i))
+		}
+	}
+
+	want := KeyDiff{Missing: make([]string, 0), Extra: make([]string, 0), Changed: make([]string, 0)}
+	for k, e := range a {
+		other, ok := b[k]
+		switch {
+		case !ok:
+			want.Extra = append(want.Extra, k)
+		case other.digest != e.digest:
+			want.Changed = append(want.Changed, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			want.Missing = append(want.Missing, k)
+		}
+	}
+	want.sort()
+
+	ta, tb := NewTree(depth), NewTree(depth)
+	for _, e := range a {
+		ta.Update(e.pos, e.key, e.digest)
+	}
+	for _, e := range b {
+		tb.Update(e.pos, e.key, e.digest)
+	}
+	return ta, tb, want
+}
+
+func TestDiffFindsEveryDivergedKey(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 11))
+	for trial := range 200 {
+		depth, keys, changes := rng.IntN(10), 1+rng.IntN(2000), rng.IntN(100)
+		a, b, want := divergedReplicas(rng, depth, keys, changes)
+
+		got, err := a.Diff(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got.Missing, want.Missing) || !slices.Equal(got.Extra, want.Extra) || !slices.Equal(got.Changed, want.Changed) {
+			t.Fatalf("trial %d (depth %d, %d keys, %d changes): Diff got %+v, want %+v", trial, depth, keys, changes, got, want)
+		}
+		if (a.RootHash() == b.RootHash()) != want.Empty() {
+			t.Fatalf("trial %d: roots equal is %v, diff empty is %v", trial, a.RootHash() == b.RootHash(), want.Empty())
+		}
+	}
+}
+
+func TestDiffRemoteKeysFindsEveryDivergedKey(t *testing.T) {
+	rng := rand.New(rand.NewPCG(13, 17))
+	for trial := range 200 {
+		depth, keys, changes := rng.IntN(10), 1+rng.IntN(2000), rng.IntN(100)
+		a, b, want := divergedReplicas(rng, depth, keys, changes)
+
+		fetchedLeaves := 0
+		got, err := a.DiffRemoteKeys(b.Hashes, func(leaves []int) ([]map[string]Digest, error) {
+			fetchedLeaves += len(leaves)
+			return b.LeafDigests(leaves)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got.Missing, want.Missing) || !slices.Equal(got.Extra, want.Extra) || !slices.Equal(got.Changed, want.Changed) {
+			t.Fatalf("trial %d (depth %d, %d keys, %d changes): DiffRemoteKeys got %+v, want %+v", trial, depth, keys, changes, got, want)
+		}
+		if diverged := len(want.Missing) + len(want.Extra) + len(want.Changed); fetchedLeaves > diverged {
+			t.Fatalf("trial %d: fetched %d leaves for %d diverged keys", trial, fetchedLeaves, diverged)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCorruptSnapshot = errors.New("merkle snapshot is corrupt")
+)
+
+// maxSnapshotDepth bounds the depth accepted from a snapshot, matching the
+// deepest tree the configuration allows.
+const maxSnapshotDepth = 24
+
+// Snapshot is the persisted form of a tree: its shape, the digest of every
+// key per non-empty leaf, and the root the leaves must hash to. Inner nodes
+// are recomputed on load, which costs 2^Depth hashes rather than a scan of the
+// store.
+type Snapshot struct {
+	Start  uint64                    `json:"start"`  // Exclusive lower bound of the range
+	End    uint64                    `json:"end"`    // Inclusive upper bound of the range
+	Depth  int                       `json:"depth"`  // Levels below the root
+	Root   string                    `json:"root"`   // Hex root hash when the snapshot was taken
+	Leaves map[int]map[string]string `json:"leaves"` // Leaf index → key → hex digest
+}
+
+// Snapshot captures the tree for persistence.
+func (t *Tree) Snapshot() Snapshot {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s := Snapshot{
+		Start:  t.Start,
+		End:    t.End,
+		Depth:  t.Depth,
+		Root:   hex.EncodeToString(t.nodes[1][:]),
+		Leaves: make(map[int]map[string]string),
+	}
+	for leaf, keys := range t.leaves {
+		if len(keys) == 0 {
+			continue
+		}
+		digests := make(map[string]string, len(keys))
+		for k, d := range keys {
+			digests[k] = hex.EncodeToString(d[:])
+		}
+		s.Leaves[leaf] = digests
+	}
+	return s
+}
+
+// FromSnapshot rebuilds a tree from a snapshot. It fails with
+// ErrCorruptSnapshot if the snapshot is malformed or its leaves do not hash to
+// the recorded root.
+func FromSnapshot(s Snapshot) (*Tree, error) {
+	if s.Depth < 0 || s.Depth > maxSnapshotDepth {
+		return nil, fmt.Errorf("%w: depth %d", ErrCorruptSnapshot, s.Depth)
+	}
+	t := NewRangeTree(s.Start, s.End, s.Depth)
+	for leaf, keys := range s.Leaves {
+		if leaf < 0 || leaf >= len(t.leaves) {
+			return nil, fmt.Errorf("%w: leaf %d", ErrCorruptSnapshot, leaf)
+		}
+		t.leaves[leaf] = make(map[string]Digest, len(keys))
+		for k, h := range keys {
+			var d Digest
+			if len(h) != hex.EncodedLen(len(d)) {
+				return nil, fmt.Errorf("%w: digest of %q", ErrCorruptSnapshot, k)
+			}
+			if _, err := hex.Decode(d[:], []byte(h)); err != nil {
+				return nil, fmt.Errorf("%w: digest of %q", ErrCorruptSnapshot, k)
+			}
+			t.leaves[leaf][k] = d
+			t.toggle(leaf, k, d)
+		}
+		t.rehashPath(leaf)
+	}
+	if root := hex.EncodeToString(t.nodes[1][:]); root != s.Root {
+		return nil, fmt.Errorf("%w: root %s, recorded %s", ErrCorruptSnapshot, root, s.Root)
+	}
+	return t, nil
+}
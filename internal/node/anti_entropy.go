@@ -0,0 +1,289 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/merkle"
+	"GossamerDB/internal/storage"
+)
+
+// AntiEntropyStats counts the work done by background repair since the node
+// started.
+type AntiEntropyStats struct {
+	Runs           uint64    `json:"runs"`           // Completed runs
+	RangesChecked  uint64    `json:"rangesChecked"`  // Ranges whose trees were compared with a replica
+	RangesDiverged uint64    `json:"rangesDiverged"` // Ranges whose trees differed
+	KeysCompared   uint64    `json:"keysCompared"`   // Keys the trees disagreed on whose versions were compared
+	KeysRepaired   uint64    `json:"keysRepaired"`   // Keys whose versions differed and were merged
+	BytesExchanged uint64    `json:"bytesExchanged"` // Bytes fetched from and sent to replicas
+	BudgetHits     uint64    `json:"budgetHits"`     // Runs cut short by the key or byte budget
+	LastRun        time.Time `json:"lastRun"`        // When the last run finished
+}
+
+// antiEntropy holds the stats of background repair.
+type antiEntropy struct {
+	mu    sync.Mutex
+	stats AntiEntropyStats
+}
+
+// repairBudget bounds the work of one anti-entropy run. A zero limit means no
+// limit.
+type repairBudget struct {
+	keys, bytes       int64
+	maxKeys, maxBytes int64
+}
+
+func (b *repairBudget) exhausted() bool {
+	return (b.maxKeys > 0 && b.keys >= b.maxKeys) || (b.maxBytes > 0 && b.bytes >= b.maxBytes)
+}
+
+// RunAntiEntropy repairs divergent replicas in the background until ctx is
+// done. Every AntiEntropyIntervalInSeconds, plus up to 10% jitter so nodes do
+// not all repair at once, it compares each range it replicates with a random
+// live replica and merges the keys the trees disagree on, on both sides.
+func (n *DataNode) RunAntiEntropy(ctx context.Context) {
+	cfg := config.ConfigObj.Repair
+	if !cfg.Enabled {
+		log.Printf("[ANTI-ENTROPY] Disabled")
+		return
+	}
+	interval := time.Duration(cfg.AntiEntropyIntervalInSeconds) * time.Second
+	for {
+		wait := interval + time.Duration(rand.Int64N(int64(interval/10)+1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		n.antiEntropyRun(ctx)
+	}
+}
+
+// antiEntropyRun makes one pass over the replicated ranges, in random order
+// so that runs cut short by the budget do not always skip the same ranges.
+func (n *DataNode) antiEntropyRun(ctx context.Context) {
+	cfg := config.ConfigObj.Repair
+	budget := &repairBudget{maxKeys: int64(cfg.MaxKeysPerRun), maxBytes: cfg.MaxBytesPerRun}
+	started := time.Now()
+
+	ranges := n.replicaRanges()
+	rand.Shuffle(len(ranges), func(i, j int) { ranges[i], ranges[j] = ranges[j], ranges[i] })
+
+	checked, diverged := 0, 0
+	for _, p := range ranges {
+		if budget.exhausted() || ctx.Err() != nil {
+			break
+		}
+		peer := n.pickRepairPeer(p.Replicas)
+		if peer == "" {
+			continue
+		}
+		diff, err := n.DiffWithPeer(ctx, peer, p.Range)
+		if err != nil {
+			log.Printf("[ERROR] Anti-entropy failed to diff %s with %s: %v", p.Range, peer, err)
+			continue
+		}
+		checked++
+		if diff.Empty() {
+			continue
+		}
+		diverged++
+		if err := n.repairKeys(ctx, peer, diff, budget); err != nil {
+			log.Printf("[ERROR] Anti-entropy failed to repair %s with %s: %v", p.Range, peer, err)
+		}
+	}
+
+	n.antiEntropy.mu.Lock()
+	st := &n.antiEntropy.stats
+	st.Runs++
+	st.RangesChecked += uint64(checked)
+	st.RangesDiverged += uint64(diverged)
+	if budget.exhausted() {
+		st.BudgetHits++
+	}
+	st.LastRun = time.Now()
+	n.antiEntropy.mu.Unlock()
+	log.Printf("[ANTI-ENTROPY] Checked %d ranges, %d diverged, in %s", checked, diverged, time.Since(started))
+}
+
+// replicaRanges returns the partitions this node replicates: those of the
+// served partition map or, until a rebalance is applied, of the ring's current
+// map, which replicas with the same view of the ring agree on.
+func (n *DataNode) replicaRanges() []hashring.Partition {
+	pm := n.PartitionMap()
+	if pm == nil {
+		pm = n.followRingMap()
+	}
+	if pm == nil {
+		return nil
+	}
+	parts := make([]hashring.Partition, 0)
+	for _, p := range pm.Partitions {
+		if slices.Contains(p.Replicas, n.id) {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// followRingMap moves the Merkle trees to the ring's current partition map
+// when no map is served, rebuilding them only when ownership changed since
+// they were last built. It returns nil when placement has no token ranges or
+// a rebalance is being applied.
+func (n *DataNode) followRingMap() *hashring.PartitionMap {
+	ring, err := n.tokenRing()
+	if err != nil {
+		return nil
+	}
+	if !n.rebalanceMu.TryLock() {
+		return nil
+	}
+	defer n.rebalanceMu.Unlock()
+	if n.PartitionMap() != nil {
+		return nil
+	}
+
+	n.mu.RLock()
+	current := n.merkleTrees.pm
+	n.mu.RUnlock()
+	next, err := ring.PartitionMap(current)
+	if err != nil {
+		return nil
+	}
+	if current == nil || next.Epoch != current.Epoch {
+		n.resetMerkleTrees(next)
+		log.Printf("[ANTI-ENTROPY] Merkle trees follow ring partition map epoch %d", next.Epoch)
+	}
+	return next
+}
+
+// pickRepairPeer picks a random live replica other than this node.
+func (n *DataNode) pickRepairPeer(replicas []string) string {
+	n.mu.RLock()
+	isUp := n.isUp
+	n.mu.RUnlock()
+
+	peers := make([]string, 0, len(replicas))
+	for _, id := range replicas {
+		if id != n.id && (isUp == nil || isUp(id)) {
+			peers = append(peers, id)
+		}
+	}
+	if len(peers) == 0 {
+		return ""
+	}
+	return peers[rand.IntN(len(peers))]
+}
+
+// repairKeys exchanges the keys the trees of this node and peer disagree on.
+// Versions only the peer has are merged locally and versions only this node
+// has are sent to the peer; both sides merge through their conflict resolver.
+func (n *DataNode) repairKeys(ctx context.Context, peer string, diff merkle.KeyDiff, budget *repairBudget) error {
+	base, err := n.dataURL(peer)
+	if err != nil {
+		return err
+	}
+
+	compared, repaired := 0, 0
+	var exchanged int64
+	defer func() {
+		n.antiEntropy.mu.Lock()
+		n.antiEntropy.stats.KeysCompared += uint64(compared)
+		n.antiEntropy.stats.KeysRepaired += uint64(repaired)
+		n.antiEntropy.stats.BytesExchanged += uint64(exchanged)
+		n.antiEntropy.mu.Unlock()
+	}()
+
+	for _, key := range slices.Concat(diff.Missing, diff.Changed, diff.Extra) {
+		if budget.exhausted() {
+			return nil
+		}
+		budget.keys++
+		compared++
+
+		theirs, size, err := fetchKey(ctx, base, key)
+		if err != nil {
+			return err
+		}
+		budget.bytes += int64(size)
+		exchanged += int64(size)
+		n.mu.RLock()
+		mine, err := n.store.Get(key)
+		n.mu.RUnlock()
+		if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return err
+		}
+		if sameVersions(mine, theirs) {
+			// Written on both sides since the trees were compared.
+			continue
+		}
+		repaired++
+		if len(theirs) > 0 {
+			if err := n.ApplyVersions(key, theirs); err != nil {
+				return err
+			}
+		}
+		if len(mine) > 0 {
+			size, err := sendEntry(ctx, base, RangeEntry{Key: key, Versions: mine})
+			if err != nil {
+				return err
+			}
+			budget.bytes += int64(size)
+			exchanged += int64(size)
+		}
+	}
+	return nil
+}
+
+// fetchKey reads the versions of key the peer at base holds, none if it does
+// not have the key, and the size of the response.
+func fetchKey(ctx context.Context, base, key string) ([]conflict.VersionedValue, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/internal/keys/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	var entry RangeEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, 0, err
+	}
+	return entry.Versions, len(body), nil
+}
+
+// sameVersions reports whether two replicas hold the same versions of a key,
+// in any order.
+func sameVersions(a, b []conflict.VersionedValue) bool {
+	return len(a) == len(b) && versionsDigest(a) == versionsDigest(b)
+}
+
+// AntiEntropyStats returns the counters of background repair.
+func (n *DataNode) AntiEntropyStats() AntiEntropyStats {
+	n.antiEntropy.mu.Lock()
+	defer n.antiEntropy.mu.Unlock()
+	return n.antiEntropy.stats
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"GossamerDB/internal/merkle"
+)
+
+func TestReplicaRangesFollowRingWithoutServedMap(t *testing.T) {
+	n := newTestNode(t, "node-a", "node-b", "node-c", "node-d")
+	parts := n.replicaRanges()
+	if len(parts) == 0 {
+		t.Fatal("no ranges to repair before a partition map is served")
+	}
+	trees := n.MerkleRanges()
+	for _, p := range parts {
+		if !slices.Contains(p.Replicas, "node-a") {
+			t.Errorf("range %s is not replicated by this node: %v", p.Range, p.Replicas)
+		}
+		if !slices.Contains(trees, p.Range) {
+			t.Errorf("no Merkle tree for range %s", p.Range)
+		}
+	}
+	if len(trees) != len(parts) {
+		t.Errorf("%d Merkle trees for %d replicated ranges", len(trees), len(parts))
+	}
+}
+
+func TestAntiEntropySpreadsDeletes(t *testing.T) {
+	peers, members := servePeers(t, "node-b")
+	n := newTestNode(t, "node-a")
+	ring, err := n.tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.AddNode(members[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Put("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	written, err := n.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peers["node-b"].ApplyVersions("key", written); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+
+	diff := merkle.KeyDiff{Changed: []string{"key"}}
+	if err := n.repairKeys(context.Background(), "node-b", diff, &repairBudget{}); err != nil {
+		t.Fatal(err)
+	}
+	for id, replica := range map[string]*DataNode{"node-a": n, "node-b": peers["node-b"]} {
+		versions, err := replica.Get("key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 1 || !versions[0].IsTombstone() {
+			t.Errorf("%s holds %d versions after repair, want only the tombstone", id, len(versions))
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/quorum"
+	"GossamerDB/internal/storage"
+)
+
+var (
+	ErrInsufficientReplicas = errors.New("not enough replicas to reach quorum")
+	ErrQuorumTimeout        = errors.New("timed out waiting for quorum")
+)
+
+// QuorumError reports a coordinated read or write that did not reach the
+// acks its consistency level needs. It unwraps to ErrInsufficientReplicas or
+// ErrQuorumTimeout.
+type QuorumError struct {
+	Op       quorum.Op
+	Level    quorum.ConsistencyLevel
+	Required int // Fewest acks that meet the level
+	Acked    int // Acks received
+	Replicas int // Replicas of the key
+	Live     int // Replicas believed up when the request started
+	Err      error
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("%s at %s: %v: %d of %d required acks, %d of %d replicas up", e.Op, e.Level, e.Err, e.Acked, e.Required, e.Live, e.Replicas)
+}
+
+func (e *QuorumError) Unwrap() error {
+	return e.Err
+}
+
+// replicaCall performs one replica's part of a coordinated request.
+type replicaCall func(ctx context.Context, id string) ([]conflict.VersionedValue, error)
+
+type replicaResult struct {
+	node     string
+	versions []conflict.VersionedValue
+	err      error
+}
+
+// replicaSet is the replicas of a key and the region of each. Stand-ins for
+// owners that are down map to the owner in hintFor.
+type replicaSet struct {
+	ids     []string
+	regions map[string]string
+	hintFor map[string]string
+}
+
+// request is a coordinated request: the replicas it goes to and what it needs
+// from them.
+type request struct {
+	op       quorum.Op
+	level    quorum.ConsistencyLevel
+	replicas replicaSet
+	need     quorum.Requirement
+	live     int
+	fanout   int // Replicas contacted up front; the others only stand in for failures
+}
+
+// CoordinatePut writes value to every replica of key in parallel and returns
+// once enough replicas for level acknowledged it. Replicas that answer later
+// still get the write, until the request timeout. Stand-ins for owners that
+// are down count towards the level and keep the write as a hint.
+//
+// The key is read at level first, so that the new version descends from the
+// versions the replicas hold even when this node is not one of them.
+func (n *DataNode) CoordinatePut(ctx context.Context, key string, value []byte, level quorum.ConsistencyLevel) error {
+	read, err := n.newRequest(key, quorum.Read, level)
+	if err != nil {
+		return err
+	}
+	reads, err := n.fanOut(ctx, read, n.readReplica(key))
+	if err != nil {
+		return err
+	}
+	req, err := n.newRequest(key, quorum.Write, level)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	for _, r := range reads {
+		for _, v := range r.versions {
+			*n.vectorClock = n.vectorClock.Merge(v.Clock)
+		}
+	}
+	n.vectorClock.Increment(n.id)
+	vv := conflict.VersionedValue{Value: liveValue(value), Clock: n.vectorClock.Copy()}
+	n.mu.Unlock()
+
+	entry := RangeEntry{Key: key, Versions: []conflict.VersionedValue{vv}}
+	_, err = n.fanOut(ctx, req, func(ctx context.Context, id string) ([]conflict.VersionedValue, error) {
+		return nil, n.writeReplica(ctx, id, req.replicas.hintFor[id], entry)
+	})
+	return err
+}
+
+// CoordinateGet reads key from as many replicas as level needs, in parallel,
+// and merges the versions returned with the conflict resolver, which orders
+// them by vector clock. The first replica read is the key's primary under
+// bounded-load placement, so hot keys are read from nodes with spare
+// capacity; further replicas are read only in place of ones that fail.
+// Replicas that returned stale versions are read repaired. Tombstones are
+// left out of the result, and a key with only tombstones is not found.
+func (n *DataNode) CoordinateGet(ctx context.Context, key string, level quorum.ConsistencyLevel) ([]conflict.VersionedValue, error) {
+	req, err := n.newRequest(key, quorum.Read, level)
+	if err != nil {
+		return nil, err
+	}
+
+	reads, err := n.fanOut(ctx, req, n.readReplica(key))
+	if err != nil {
+		return nil, err
+	}
+	merged := n.resolveReads(reads)
+	n.readRepair(ctx, key, reads, merged)
+	live := slices.DeleteFunc(slices.Clone(merged), conflict.VersionedValue.IsTombstone)
+	if len(live) == 0 {
+		return nil, storage.ErrKeyNotFound
+	}
+	return live, nil
+}
+
+// newRequest prepares a coordinated request for key at level. It fails fast
+// with ErrInsufficientReplicas when the replicas the failure detector reports
+// up cannot meet the level, rather than waiting for the request to time out.
+func (n *DataNode) newRequest(key string, op quorum.Op, level quorum.ConsistencyLevel) (*request, error) {
+	replicas, err := n.replicasFor(key)
+	if err != nil {
+		return nil, err
+	}
+	req := &request{
+		op:       op,
+		level:    level,
+		replicas: replicas,
+		need:     n.quorum.Requirement(level, op, replicas.regions, config.ConfigObj.Gossip.Region),
+	}
+
+	n.mu.RLock()
+	isUp := n.isUp
+	n.mu.RUnlock()
+	live := make([]string, 0, len(replicas.ids))
+	for _, id := range replicas.ids {
+		if id == n.id || isUp == nil || isUp(id) {
+			live = append(live, id)
+		}
+	}
+	req.live = len(live)
+	if !req.need.IsMet(live, replicas.regions) {
+		return nil, req.error(0, ErrInsufficientReplicas)
+	}
+
+	req.fanout = len(replicas.ids)
+	if op == quorum.Read && len(req.need.PerRegion) == 0 {
+		// Regional levels read everywhere, since which replicas can stand in
+		// for a failed one depends on its region.
+		req.replicas.ids = n.readOrder(key, replicas.ids, live)
+		req.fanout = req.need.Total
+	}
+	return req, nil
+}
+
+// readOrder orders the replicas of key for reading: the primary placement
+// picks, which skips nodes at capacity when load is bounded, then the other
+// live replicas, then the rest.
+func (n *DataNode) readOrder(key string, ids, live []string) []string {
+	ordered := make([]string, 0, len(ids))
+	if primary, err := n.placement.GetPrimaryNode(key); err == nil && slices.Contains(live, primary.GetIdentifier()) {
+		ordered = append(ordered, primary.GetIdentifier())
+	}
+	for _, id := range live {
+		if !slices.Contains(ordered, id) {
+			ordered = append(ordered, id)
+		}
+	}
+	for _, id := range ids {
+		if !slices.Contains(ordered, id) {
+			ordered = append(ordered, id)
+		}
+	}
+	return ordered
+}
+
+// countLoad adds a request routed to id to its load on placements that
+// balance by load. The load of a node is the number of requests it reported
+// serving in the last interval plus those routed to it since, until its next
+// report replaces the count.
+func (n *DataNode) countLoad(id string) {
+	if lc, ok := n.placement.(interface{ AddLoad(string, int64) error }); ok {
+		_ = lc.AddLoad(id, 1)
+	}
+}
+
+func (req *request) error(acked int, err error) *QuorumError {
+	return &QuorumError{
+		Op:       req.op,
+		Level:    req.level,
+		Required: req.need.Acks(),
+		Acked:    acked,
+		Replicas: len(req.replicas.ids),
+		Live:     req.live,
+		Err:      err,
+	}
+}
+
+// replicasFor returns the nodes a request for key goes to, with the region of
+// each. The owners come from the served partition map until a rebalance cuts
+// over to the ring's view, and from the ring's preference list before any map
+// is served. Owners the failure detector reports down are replaced by the next
+// healthy nodes of the preference list, which keep the writes as hints. Nodes
+// that do not know their location are in the empty region.
+func (n *DataNode) replicasFor(key string) (replicaSet, error) {
+	pl, err := n.PreferenceList(key)
+	if err != nil {
+		return replicaSet{}, err
+	}
+	replicas := pl.Replicas
+	if pm := n.PartitionMap(); pm != nil {
+		replicas = n.servedReplicas(pm.Owners(n.keyToken(key)).Replicas, pl)
+	}
+	if len(replicas) == 0 {
+		return replicaSet{}, hashring.ErrNoNodesAvailable
+	}
+
+	rs := replicaSet{
+		ids:     make([]string, len(replicas)),
+		regions: make(map[string]string, len(replicas)),
+		hintFor: make(map[string]string),
+	}
+	for i, r := range replicas {
+		id := r.Node.GetIdentifier()
+		rs.ids[i] = id
+		if ln, ok := r.Node.(hashring.ILocatedNode); ok {
+			rs.regions[id] = ln.Region()
+		} else {
+			rs.regions[id] = ""
+		}
+		if r.HintFor != "" {
+			rs.hintFor[id] = r.HintFor
+		}
+	}
+	return rs, nil
+}
+
+// servedReplicas applies the sloppy quorum of pl to the owners of a key in
+// the served partition map: owners that are down, or no longer on the ring,
+// are replaced by the healthy nodes of pl that are not owners, in preference
+// order.
+func (n *DataNode) servedReplicas(owners []string, pl *hashring.PreferenceList) []hashring.Replica {
+	n.mu.RLock()
+	isUp := n.isUp
+	n.mu.RUnlock()
+
+	spare := make([]hashring.ICacheNode, 0, len(pl.Replicas)+len(pl.Fallbacks))
+	for _, r := range pl.Replicas {
+		spare = append(spare, r.Node)
+	}
+	spare = append(spare, pl.Fallbacks...)
+	spare = slices.DeleteFunc(spare, func(node hashring.ICacheNode) bool {
+		return slices.Contains(owners, node.GetIdentifier())
+	})
+
+	replicas := make([]hashring.Replica, 0, len(owners))
+	for _, id := range owners {
+		if id == n.id || isUp == nil || isUp(id) {
+			if node, err := n.placement.GetNode(id); err == nil {
+				replicas = append(replicas, hashring.Replica{Node: node})
+				continue
+			}
+		}
+		if len(spare) == 0 {
+			continue
+		}
+		replicas = append(replicas, hashring.Replica{Node: spare[0], HintFor: id})
+		spare = spare[1:]
+	}
+	return replicas
+}
+
+// readReplica returns a call reading the versions of key a replica holds,
+// none if it does not have the key.
+func (n *DataNode) readReplica(key string) replicaCall {
+	return func(ctx context.Context, id string) ([]conflict.VersionedValue, error) {
+		if id == n.id {
+			versions, err := n.Get(key)
+			if errors.Is(err, storage.ErrKeyNotFound) {
+				return nil, nil
+			}
+			return versions, err
+		}
+		base, err := n.dataURL(id)
+		if err != nil {
+			return nil, err
+		}
+		var entry RangeEntry
+		if err := getJSON(ctx, base+"/internal/keys/"+url.PathEscape(key), &entry); err != nil {
+			return nil, err
+		}
+		return entry.Versions, nil
+	}
+}
+
+// fanOut runs call against the first req.fanout replicas in parallel, and
+// against one more in place of each that fails, collecting the successful
+// answers until they meet its consistency level. It fails early with
+// ErrInsufficientReplicas once too many replicas failed for the level to be
+// met, and with ErrQuorumTimeout when the request timeout passes first. Calls
+// still running when it returns go on until the timeout.
+func (n *DataNode) fanOut(ctx context.Context, req *request, call replicaCall) ([]replicaRead, error) {
+	replicas := req.replicas.ids
+	timeout := requestTimeout()
+	callCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	results := make(chan replicaResult, len(replicas))
+	var wg sync.WaitGroup
+	launched := 0
+	launch := func() {
+		id := replicas[launched]
+		launched++
+		n.countLoad(id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			versions, err := call(callCtx, id)
+			results <- replicaResult{node: id, versions: versions, err: err}
+		}()
+	}
+	for launched < min(req.fanout, len(replicas)) {
+		launch()
+	}
+	// Nothing is launched once fanOut returns, so the calls can be waited on.
+	defer func() {
+		go func() {
+			wg.Wait()
+			cancel()
+		}()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	acks := make([]replicaRead, 0, len(replicas))
+	acked := make([]string, 0, len(replicas))
+	failed := make(map[string]struct{})
+	for {
+		if req.need.IsMet(acked, req.replicas.regions) {
+			return acks, nil
+		}
+		// The level can still be met only if every pending replica succeeds.
+		possible := make([]string, 0, len(replicas))
+		for _, id := range replicas {
+			if _, ok := failed[id]; !ok {
+				possible = append(possible, id)
+			}
+		}
+		if !req.need.IsMet(possible, req.replicas.regions) {
+			return acks, req.error(len(acks), ErrInsufficientReplicas)
+		}
+		select {
+		case r := <-results:
+			if r.err != nil {
+				failed[r.node] = struct{}{}
+				log.Printf("[COORDINATOR] Replica %s failed: %v", r.node, r.err)
+				if launched < len(replicas) {
+					launch()
+				}
+				continue
+			}
+			acks = append(acks, replicaRead{node: r.node, versions: r.versions})
+			acked = append(acked, r.node)
+		case <-timer.C:
+			return acks, req.error(len(acks), ErrQuorumTimeout)
+		case <-ctx.Done():
+			return acks, ctx.Err()
+		}
+	}
+}
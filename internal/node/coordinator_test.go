@@ -0,0 +1,256 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/quorum"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReplicasForFollowsServedMap(t *testing.T) {
+	n := newTestNode(t, "node-a", "node-b", "node-c", "node-d", "node-e")
+	ring, err := n.tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	served, err := ring.PartitionMap(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.partitionMu.Lock()
+	n.partitionMap = served
+	n.partitionMu.Unlock()
+
+	// Nodes joining the ring take no requests until a rebalance cuts over.
+	for _, id := range []string{"node-f", "node-g"} {
+		if err := ring.AddNode(testMember(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moved := 0
+	for i := range 1000 {
+		key := fmt.Sprintf("key-%d", i)
+		rs, err := n.replicasFor(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := served.Owners(n.keyToken(key)).Replicas
+		if !slices.Equal(rs.ids, want) {
+			t.Fatalf("%s goes to %v, served map has %v", key, rs.ids, want)
+		}
+		if len(rs.hintFor) != 0 {
+			t.Fatalf("%s has stand-ins %v with every owner up", key, rs.hintFor)
+		}
+		nodes, err := ring.GetNodesForKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(nodeIDs(nodes), want) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatal("no key moved on the ring after two nodes joined")
+	}
+}
+
+func nodeIDs(nodes []hashring.ICacheNode) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.GetIdentifier()
+	}
+	return ids
+}
+
+// testPeer is a placement node serving the data API at url.
+type testPeer struct {
+	id, url string
+}
+
+func (p testPeer) GetIdentifier() string { return p.id }
+func (p testPeer) DataURL() string       { return p.url }
+
+// servePeers starts data nodes with the given IDs, each serving the data API,
+// and returns them with the placement nodes to add to a ring to reach them.
+func servePeers(t *testing.T, ids ...string) (map[string]*DataNode, []testPeer) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	nodes := make(map[string]*DataNode, len(ids))
+	members := make([]testPeer, 0, len(ids))
+	for _, id := range ids {
+		nodes[id] = newTestNode(t, id)
+		srv := httptest.NewServer(NewServer("", nodes[id]).router)
+		t.Cleanup(srv.Close)
+		members = append(members, testPeer{id: id, url: srv.URL})
+	}
+	return nodes, members
+}
+
+// findKey returns the first key whose replicas on the ring satisfy match.
+func findKey(t *testing.T, ring *hashring.HashRing, match func(replicas []string) bool) string {
+	t.Helper()
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes, err := ring.GetNodesForKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match(nodeIDs(nodes)) {
+			return key
+		}
+	}
+}
+
+func TestPutToStandInStoresHint(t *testing.T) {
+	peers, members := servePeers(t, "node-b", "node-d")
+	n := newTestNode(t, "node-a")
+	ring, err := n.tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if err := ring.AddNode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ring.AddNode(testMember("node-c")); err != nil {
+		t.Fatal(err)
+	}
+	n.SetFailureDetector(func(id string) bool { return id != "node-c" })
+
+	key := findKey(t, ring, func(replicas []string) bool { return slices.Contains(replicas, "node-c") })
+	if err := n.CoordinatePut(context.Background(), key, []byte("value"), quorum.ConsistencyAll); err != nil {
+		t.Fatal(err)
+	}
+
+	hints := n.HintStats().Pending
+	for _, peer := range peers {
+		hints += peer.HintStats().Pending
+	}
+	if hints != 1 {
+		t.Errorf("%d hints stored for a write to %s with node-c down, want 1", hints, key)
+	}
+}
+
+func TestReplicasForStandsInForDownOwners(t *testing.T) {
+	n := newTestNode(t, "node-a", "node-b", "node-c", "node-d", "node-e")
+	n.SetFailureDetector(func(id string) bool { return id != "node-c" })
+
+	check := func(t *testing.T, owners func(key string) []string) {
+		standIns := 0
+		for i := range 1000 {
+			key := fmt.Sprintf("key-%d", i)
+			rs, err := n.replicasFor(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := owners(key)
+			if len(rs.ids) != len(want) {
+				t.Fatalf("%s goes to %v, owners are %v", key, rs.ids, want)
+			}
+			for j, id := range rs.ids {
+				switch owner, hinted := rs.hintFor[id]; {
+				case want[j] == "node-c":
+					if !hinted || owner != "node-c" || slices.Contains(want, id) {
+						t.Fatalf("%s goes to %v with stand-ins %v, owners are %v", key, rs.ids, rs.hintFor, want)
+					}
+					standIns++
+				case hinted || id != want[j]:
+					t.Fatalf("%s goes to %v with stand-ins %v, owners are %v", key, rs.ids, rs.hintFor, want)
+				}
+			}
+		}
+		if standIns == 0 {
+			t.Fatal("node-c owned none of the keys")
+		}
+	}
+
+	t.Run("preference list", func(t *testing.T) {
+		check(t, func(key string) []string {
+			nodes, err := n.placement.GetNodesForKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return nodeIDs(nodes)
+		})
+	})
+	t.Run("served map", func(t *testing.T) {
+		ring, err := n.tokenRing()
+		if err != nil {
+			t.Fatal(err)
+		}
+		served, err := ring.PartitionMap(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.partitionMu.Lock()
+		n.partitionMap = served
+		n.partitionMu.Unlock()
+		check(t, func(key string) []string {
+			return served.Owners(n.keyToken(key)).Replicas
+		})
+	})
+}
+
+func TestPutFromNonReplicasOrdersWrites(t *testing.T) {
+	_, members := servePeers(t, "node-b", "node-c", "node-d")
+	coordinators := make(map[string]*DataNode)
+	for _, id := range []string{"node-x", "node-y"} {
+		other := "node-y"
+		if id == other {
+			other = "node-x"
+		}
+		n := newTestNode(t, id, other)
+		ring, err := n.tokenRing()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range members {
+			if err := ring.AddNode(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		coordinators[id] = n
+	}
+	ring, err := coordinators["node-x"].tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := findKey(t, ring, func(replicas []string) bool {
+		return !slices.Contains(replicas, "node-x") && !slices.Contains(replicas, "node-y")
+	})
+
+	// Concurrent clocks {node-x:1} and {node-y:1} would resolve to node-y's
+	// write, so the second write must have read the first one's clock.
+	ctx := context.Background()
+	if err := coordinators["node-y"].CoordinatePut(ctx, key, []byte("first"), quorum.ConsistencyQuorum); err != nil {
+		t.Fatal(err)
+	}
+	if err := coordinators["node-x"].CoordinatePut(ctx, key, []byte("second"), quorum.ConsistencyQuorum); err != nil {
+		t.Fatal(err)
+	}
+	versions, err := coordinators["node-y"].CoordinateGet(ctx, key, quorum.ConsistencyQuorum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || string(versions[0].Value) != "second" {
+		t.Errorf("read %q after two writes, want only \"second\"", versionValues(versions))
+	}
+}
+
+func versionValues(versions []conflict.VersionedValue) []string {
+	values := make([]string, len(versions))
+	for i, v := range versions {
+		values[i] = string(v.Value)
+	}
+	return values
+}
@@ -0,0 +1,485 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("range batch checksum mismatch")
+	ErrNoDataURL        = errors.New("node has no data URL")
+)
+
+// RangeEntry is one key with all of its sibling versions.
+type RangeEntry struct {
+	Key      string                    `json:"key"`
+	Versions []conflict.VersionedValue `json:"versions"`
+}
+
+// RangeBatch is one page of a token range being streamed to a new owner.
+// Keys are sent in ascending order, so LastKey is the cursor to resume from.
+type RangeBatch struct {
+	Entries  []RangeEntry `json:"entries"`
+	LastKey  string       `json:"lastKey"`
+	Done     bool         `json:"done"`
+	Checksum string       `json:"checksum"` // hex SHA-256 of the JSON encoded entries
+}
+
+// dualWrite is a node writes to a range being handed off are mirrored to.
+// It lapses at expires unless the node renews it.
+type dualWrite struct {
+	expires time.Time
+	keys    []string // Sorted keys of the range listed when the stream started
+}
+
+// handoffKey identifies an incoming transfer, to resume it after a failure.
+type handoffKey struct {
+	source string
+	r      hashring.TokenRange
+}
+
+// ReadRange returns up to limit keys of r that sort after the given key, with
+// all their versions, for the stream of r to dest.
+func (n *DataNode) ReadRange(r hashring.TokenRange, dest, after string, limit int) (RangeBatch, error) {
+	keys, err := n.rangeKeys(r, dest, after == "")
+	if err != nil {
+		return RangeBatch{}, err
+	}
+	i, found := slices.BinarySearch(keys, after)
+	if found {
+		i++
+	}
+	keys = keys[i:]
+
+	batch := RangeBatch{Entries: make([]RangeEntry, 0, min(limit, len(keys))), LastKey: after, Done: len(keys) <= limit}
+	for _, k := range keys[:min(limit, len(keys))] {
+		versions, err := n.Get(k)
+		if err != nil {
+			continue // deleted since listing
+		}
+		batch.Entries = append(batch.Entries, RangeEntry{Key: k, Versions: versions})
+		batch.LastKey = k
+	}
+	sum, err := checksumEntries(batch.Entries)
+	if err != nil {
+		return RangeBatch{}, err
+	}
+	batch.Checksum = sum
+	if batch.Done {
+		n.handoffMu.Lock()
+		if dw := n.outgoing[r][dest]; dw != nil {
+			dw.keys = nil
+		}
+		n.handoffMu.Unlock()
+	}
+	return batch, nil
+}
+
+// rangeKeys returns the keys of r in ascending order. They are listed once
+// when a stream to dest starts, or restarts when fresh is set, and kept with
+// its dual-write registration for the following batches; keys written after
+// the listing reach dest as dual-writes.
+func (n *DataNode) rangeKeys(r hashring.TokenRange, dest string, fresh bool) ([]string, error) {
+	n.handoffMu.Lock()
+	if dw := n.outgoing[r][dest]; dw != nil && dw.keys != nil && !fresh {
+		keys := dw.keys
+		n.handoffMu.Unlock()
+		return keys, nil
+	}
+	n.handoffMu.Unlock()
+
+	ring, err := n.tokenRing()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for _, k := range n.ListKeys() {
+		token, err := ring.Token(k)
+		if err != nil {
+			return nil, err
+		}
+		if r.Contains(token) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	n.handoffMu.Lock()
+	if dw := n.outgoing[r][dest]; dw != nil {
+		dw.keys = keys
+	}
+	n.handoffMu.Unlock()
+	return keys, nil
+}
+
+// ApplyVersions merges versions received from another replica into the local
+// store without bumping this node's clock. Like local writes, they are
+// mirrored to nodes the key's range is being handed off to.
+func (n *DataNode) ApplyVersions(key string, versions []conflict.VersionedValue) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.applyVersions(key, versions); err != nil {
+		return err
+	}
+	for _, v := range versions {
+		n.forwardWrite(key, v)
+	}
+	return nil
+}
+
+// applyVersions stores versions and folds their clocks into the node clock so
+// later local writes supersede them, then updates the key's Merkle leaf.
+// Callers must hold n.mu.
+func (n *DataNode) applyVersions(key string, versions []conflict.VersionedValue) error {
+	for _, v := range versions {
+		if err := n.store.Set(key, v); err != nil {
+			return err
+		}
+		*n.vectorClock = n.vectorClock.Merge(v.Clock)
+	}
+	n.updateMerkle(key)
+	return nil
+}
+
+// StartDualWrite forwards writes to keys in r to dest, so a new owner
+// streaming r does not miss writes made during the transfer. Forwarding stops
+// at StopDualWrite, or once the dual-write lease passes without dest renewing
+// it by calling StartDualWrite again. It reports whether dest had no lease,
+// in which case writes to keys it was already sent may have been missed.
+func (n *DataNode) StartDualWrite(r hashring.TokenRange, dest string) bool {
+	lease := time.Duration(config.ConfigObj.Handoff.DualWriteLeaseSeconds) * time.Second
+	now := time.Now()
+
+	n.handoffMu.Lock()
+	defer n.handoffMu.Unlock()
+	if n.outgoing[r] == nil {
+		n.outgoing[r] = make(map[string]*dualWrite)
+	}
+	dw, ok := n.outgoing[r][dest]
+	lapsed := !ok || now.After(dw.expires)
+	if lapsed {
+		log.Printf("[HANDOFF] Dual-writing %s to %s", r, dest)
+		dw = &dualWrite{}
+		n.outgoing[r][dest] = dw
+	}
+	dw.expires = now.Add(lease)
+	return lapsed
+}
+
+// StopDualWrite ends forwarding of writes to r to dest.
+func (n *DataNode) StopDualWrite(r hashring.TokenRange, dest string) {
+	n.handoffMu.Lock()
+	defer n.handoffMu.Unlock()
+	n.stopDualWrite(r, dest)
+	log.Printf("[HANDOFF] Stopped dual-writing %s to %s", r, dest)
+}
+
+// stopDualWrite drops dest from the nodes r is mirrored to. Callers must hold
+// n.handoffMu.
+func (n *DataNode) stopDualWrite(r hashring.TokenRange, dest string) {
+	delete(n.outgoing[r], dest)
+	if len(n.outgoing[r]) == 0 {
+		delete(n.outgoing, r)
+	}
+}
+
+// forwardWrite sends a write to every node a range containing key is being
+// handed off to.
+func (n *DataNode) forwardWrite(key string, value conflict.VersionedValue) {
+	n.handoffMu.Lock()
+	if len(n.outgoing) == 0 {
+		n.handoffMu.Unlock()
+		return
+	}
+	ring, err := n.tokenRing()
+	if err != nil {
+		n.handoffMu.Unlock()
+		return
+	}
+	token, err := ring.Token(key)
+	if err != nil {
+		n.handoffMu.Unlock()
+		return
+	}
+	now := time.Now()
+	dests := make([]string, 0)
+	for r, ds := range n.outgoing {
+		if !r.Contains(token) {
+			continue
+		}
+		for d, dw := range ds {
+			if now.After(dw.expires) {
+				log.Printf("[HANDOFF] Dual-write lease of %s to %s lapsed", r, d)
+				n.stopDualWrite(r, d)
+				continue
+			}
+			dests = append(dests, d)
+		}
+	}
+	n.handoffMu.Unlock()
+
+	for _, dest := range dests {
+		go func(dest string) {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout())
+			defer cancel()
+			base, err := n.dataURL(dest)
+			if err == nil {
+				err = sendWrite(ctx, base, key, value)
+			}
+			if err != nil {
+				log.Printf("[ERROR] Failed dual-writing %s to %s: %v", key, dest, err)
+			}
+		}(dest)
+	}
+}
+
+// sendWrite applies a write on the node serving the data API at base.
+func sendWrite(ctx context.Context, base, key string, value conflict.VersionedValue) error {
+	_, err := sendEntry(ctx, base, RangeEntry{Key: key, Versions: []conflict.VersionedValue{value}})
+	return err
+}
+
+// sendEntry merges a key's versions into the node serving the data API at
+// base, returning the number of bytes sent.
+func sendEntry(ctx context.Context, base string, entry RangeEntry) (int, error) {
+	return postEntry(ctx, base+"/internal/replicate", entry)
+}
+
+// sendHintedEntry merges a key's versions into the node serving the data API
+// at base, which stands in for the owner target and keeps them as hints until
+// target is back.
+func sendHintedEntry(ctx context.Context, base, target string, entry RangeEntry) (int, error) {
+	return postEntry(ctx, base+"/internal/replicate?"+url.Values{"hintFor": {target}}.Encode(), entry)
+}
+
+func postEntry(ctx context.Context, u string, entry RangeEntry) (int, error) {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return len(payload), nil
+}
+
+// streamRange pulls t.Range from t.Source in batches, verifying each batch's
+// checksum and throttling to the configured rate. Progress is kept per source
+// and range, so a failed stream resumes where it stopped on the next call.
+func (n *DataNode) streamRange(ctx context.Context, t hashring.Transfer) error {
+	base, err := n.dataURL(t.Source)
+	if err != nil {
+		return err
+	}
+	cfg := config.ConfigObj.Handoff
+	key := handoffKey{source: t.Source, r: t.Range}
+
+	n.handoffMu.Lock()
+	cursor := n.handoffs[key]
+	n.handoffMu.Unlock()
+	if cursor != "" {
+		log.Printf("[HANDOFF] Resuming %s from %s after %q", t.Range, t.Source, cursor)
+	}
+
+	for {
+		started := time.Now()
+		q := rangeQuery(t.Range)
+		q.Set("after", cursor)
+		q.Set("limit", strconv.Itoa(cfg.BatchSize))
+		q.Set("destination", n.id)
+		batch, size, err := fetchRangeBatch(ctx, base+"/internal/range?"+q.Encode())
+		if err != nil {
+			return fmt.Errorf("streaming %s from %s: %w", t.Range, t.Source, err)
+		}
+
+		n.mu.Lock()
+		for _, e := range batch.Entries {
+			if err := n.applyVersions(e.Key, e.Versions); err != nil {
+				n.mu.Unlock()
+				return err
+			}
+		}
+		n.mu.Unlock()
+
+		cursor = batch.LastKey
+		n.handoffMu.Lock()
+		n.handoffs[key] = cursor
+		n.handoffMu.Unlock()
+
+		if batch.Done {
+			n.handoffMu.Lock()
+			delete(n.handoffs, key)
+			n.handoffMu.Unlock()
+			log.Printf("[HANDOFF] Finished streaming %s from %s", t.Range, t.Source)
+			return nil
+		}
+		if err := throttle(ctx, size, started, cfg.MaxBytesPerSecond); err != nil {
+			return err
+		}
+	}
+}
+
+// fetchRangeBatch requests one batch of a range from u and checks its
+// checksum. It also returns the size of the response body.
+func fetchRangeBatch(ctx context.Context, u string) (RangeBatch, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return RangeBatch{}, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return RangeBatch{}, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return RangeBatch{}, 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return RangeBatch{}, 0, err
+	}
+	var batch RangeBatch
+	if err := json.Unmarshal(buf.Bytes(), &batch); err != nil {
+		return RangeBatch{}, 0, err
+	}
+	sum, err := checksumEntries(batch.Entries)
+	if err != nil {
+		return RangeBatch{}, 0, err
+	}
+	if sum != batch.Checksum {
+		return RangeBatch{}, 0, ErrChecksumMismatch
+	}
+	return batch, buf.Len(), nil
+}
+
+// keepDualWrites renews the dual-write leases of the transfers received on
+// streamed, which finished streaming, until ctx is done.
+func (n *DataNode) keepDualWrites(ctx context.Context, streamed <-chan hashring.Transfer) {
+	lease := time.Duration(config.ConfigObj.Handoff.DualWriteLeaseSeconds) * time.Second
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	transfers := make([]hashring.Transfer, 0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-streamed:
+			transfers = append(transfers, t)
+		case <-ticker.C:
+			for _, t := range transfers {
+				if err := n.renewHandoff(ctx, t); err != nil {
+					log.Printf("[ERROR] Failed to renew dual-writes of %s on %s: %v", t.Range, t.Source, err)
+				}
+			}
+		}
+	}
+}
+
+// renewHandoff renews the lease of t.Source on dual-writing t.Range to this
+// node. Writes made after a lease lapsed are left to anti-entropy.
+func (n *DataNode) renewHandoff(ctx context.Context, t hashring.Transfer) error {
+	base, err := n.dataURL(t.Source)
+	if err != nil {
+		return err
+	}
+	q := rangeQuery(t.Range)
+	q.Set("destination", n.id)
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout())
+	defer cancel()
+	var resp struct {
+		Lapsed bool `json:"lapsed"`
+	}
+	if err := postJSON(ctx, base+"/internal/range/renew?"+q.Encode(), nil, &resp); err != nil {
+		return err
+	}
+	if resp.Lapsed {
+		log.Printf("[HANDOFF] Dual-write lease of %s on %s had lapsed, writes since may be missing", t.Range, t.Source)
+	}
+	return nil
+}
+
+// completeHandoff tells the source it can stop dual-writing r to this node.
+func (n *DataNode) completeHandoff(ctx context.Context, t hashring.Transfer) error {
+	base, err := n.dataURL(t.Source)
+	if err != nil {
+		return err
+	}
+	q := rangeQuery(t.Range)
+	q.Set("destination", n.id)
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout())
+	defer cancel()
+	return postJSON(ctx, base+"/internal/range/complete?"+q.Encode(), nil, nil)
+}
+
+// requestTimeout bounds each request a node makes to another node's data API.
+func requestTimeout() time.Duration {
+	return time.Duration(config.ConfigObj.Cluster.RequestTimeoutMs) * time.Millisecond
+}
+
+// dataURL resolves the data API of a node on the ring.
+func (n *DataNode) dataURL(id string) (string, error) {
+	node, err := n.placement.GetNode(id)
+	if err != nil {
+		return "", err
+	}
+	addr, ok := node.(interface{ DataURL() string })
+	if !ok || addr.DataURL() == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoDataURL, id)
+	}
+	return addr.DataURL(), nil
+}
+
+func checksumEntries(entries []RangeEntry) (string, error) {
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// throttle sleeps long enough that sending size bytes since started stays
+// under rate bytes per second. A rate of 0 disables throttling.
+func throttle(ctx context.Context, size int, started time.Time, rate int64) error {
+	if rate <= 0 {
+		return nil
+	}
+	wait := time.Duration(float64(size)/float64(rate)*float64(time.Second)) - time.Since(started)
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
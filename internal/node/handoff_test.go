@@ -0,0 +1,102 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+)
+
+func TestDualWriteLeaseLapses(t *testing.T) {
+	n := newTestNode(t, "node-a")
+	all := hashring.TokenRange{Start: 0, End: 0}
+	if !n.StartDualWrite(all, "node-b") {
+		t.Error("first StartDualWrite reported a live lease")
+	}
+	if n.StartDualWrite(all, "node-b") {
+		t.Error("renewing a live lease reported it lapsed")
+	}
+
+	n.handoffMu.Lock()
+	n.outgoing[all]["node-b"].expires = time.Now().Add(-time.Second)
+	n.handoffMu.Unlock()
+	n.forwardWrite("key", conflict.VersionedValue{Value: []byte("value"), Clock: conflict.VectorClock{"node-a": 1}})
+	n.handoffMu.Lock()
+	left := len(n.outgoing)
+	n.handoffMu.Unlock()
+	if left != 0 {
+		t.Errorf("%d ranges still dual-written after the lease lapsed", left)
+	}
+	if !n.StartDualWrite(all, "node-b") {
+		t.Error("StartDualWrite after the lease lapsed reported it live")
+	}
+}
+
+func TestReadRangePagesThroughRange(t *testing.T) {
+	n := newTestNode(t, "node-a")
+	ring, err := n.tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := hashring.TokenRange{Start: 1 << 63, End: 0}
+	want := make([]string, 0)
+	for i := range 500 {
+		key := fmt.Sprintf("key-%d", i)
+		if err := n.Put(key, []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		token, err := ring.Token(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if half.Contains(token) {
+			want = append(want, key)
+		}
+	}
+	slices.Sort(want)
+
+	n.StartDualWrite(half, "node-b")
+	got := make([]string, 0, len(want))
+	after := ""
+	for {
+		batch, err := n.ReadRange(half, "node-b", after, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range batch.Entries {
+			got = append(got, e.Key)
+		}
+		after = batch.LastKey
+		if batch.Done {
+			break
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("streamed %d keys, want the %d keys of the range in order", len(got), len(want))
+	}
+}
+
+func TestCompleteHandoffReportsRejection(t *testing.T) {
+	n := newTestNode(t, "node-a")
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(source.Close)
+	ring, err := n.tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.AddNode(testPeer{id: "node-b", url: source.URL}); err != nil {
+		t.Fatal(err)
+	}
+	transfer := hashring.Transfer{Range: hashring.TokenRange{Start: 1, End: 2}, Source: "node-b", Destination: "node-a"}
+	if err := n.completeHandoff(context.Background(), transfer); err == nil {
+		t.Error("completing a handoff the source rejected succeeded")
+	}
+}
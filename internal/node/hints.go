@@ -0,0 +1,242 @@
+package node
+
+import (
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+)
+
+// Hint is a write this node accepted as a stand-in for an owner that was down,
+// kept until the owner is back.
+type Hint struct {
+	Target  string                  `json:"target"`  // Owner the write is meant for
+	Key     string                  `json:"key"`     // Key written
+	Value   conflict.VersionedValue `json:"value"`   // Version to deliver
+	Created time.Time               `json:"created"` // When the hint was stored
+}
+
+// HintStats counts what happened to hints since the node started.
+type HintStats struct {
+	Pending  int    `json:"pending"`  // Hints waiting for their owner
+	Stored   uint64 `json:"stored"`   // Hints accepted
+	Replayed uint64 `json:"replayed"` // Hints delivered to their owner
+	Expired  uint64 `json:"expired"`  // Hints dropped for exceeding the maximum age
+	Dropped  uint64 `json:"dropped"`  // Hints dropped because their owner had too many
+}
+
+// hintStore keeps hints per target, oldest first.
+type hintStore struct {
+	mu        sync.Mutex
+	hints     map[string][]Hint
+	replaying map[string]bool
+
+	stored   atomic.Uint64
+	replayed atomic.Uint64
+	expired  atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+func newHintStore() *hintStore {
+	return &hintStore{
+		hints:     make(map[string][]Hint),
+		replaying: make(map[string]bool),
+	}
+}
+
+// StoreHint records a write meant for target. When target already has the
+// maximum number of hints, its oldest hint is dropped.
+func (n *DataNode) StoreHint(target, key string, value conflict.VersionedValue) {
+	cfg := config.ConfigObj.HintedHandoff
+	hs := n.hints
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	queue := hs.expire(target, time.Now())
+	if over := len(queue) - cfg.MaxHintsPerNode + 1; over > 0 {
+		queue = queue[over:]
+		hs.dropped.Add(uint64(over))
+		log.Printf("[HINTS] Dropped %d oldest hints for %s, limit is %d", over, target, cfg.MaxHintsPerNode)
+	}
+	hs.hints[target] = append(queue, Hint{Target: target, Key: key, Value: value, Created: time.Now()})
+	hs.stored.Add(1)
+}
+
+// ExpireHints drops every hint older than the maximum hint age.
+func (n *DataNode) ExpireHints() {
+	hs := n.hints
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	now := time.Now()
+	for target := range hs.hints {
+		hs.expire(target, now)
+	}
+}
+
+// HintTargets returns the owners that have hints waiting, sorted.
+func (n *DataNode) HintTargets() []string {
+	hs := n.hints
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(hs.hints))
+}
+
+// expire drops target's expired hints and returns the rest. Callers must hold
+// hs.mu.
+func (hs *hintStore) expire(target string, now time.Time) []Hint {
+	maxAge := time.Duration(config.ConfigObj.HintedHandoff.MaxHintAgeSeconds) * time.Second
+	queue := hs.hints[target]
+	i := 0
+	for i < len(queue) && now.Sub(queue[i].Created) > maxAge {
+		i++
+	}
+	if i > 0 {
+		hs.expired.Add(uint64(i))
+		log.Printf("[HINTS] Expired %d hints for %s", i, target)
+	}
+	queue = queue[i:]
+	if len(queue) == 0 {
+		delete(hs.hints, target)
+		return nil
+	}
+	hs.hints[target] = queue
+	return queue
+}
+
+// ReplayHints delivers the hints kept for target to its data API at base, at
+// most ReplayRatePerSecond hints per second. Delivery stops at the first
+// failure, keeping the remaining hints for the next time the target comes
+// back. Only one replay per target runs at a time. Delivered keys this node
+// does not own and has no more hints for are dropped from its store.
+func (n *DataNode) ReplayHints(ctx context.Context, target, base string) {
+	hs := n.hints
+	hs.mu.Lock()
+	if hs.replaying[target] || len(hs.hints[target]) == 0 {
+		hs.mu.Unlock()
+		return
+	}
+	hs.replaying[target] = true
+	hs.mu.Unlock()
+	defer func() {
+		hs.mu.Lock()
+		delete(hs.replaying, target)
+		hs.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(time.Second / time.Duration(config.ConfigObj.HintedHandoff.ReplayRatePerSecond))
+	defer ticker.Stop()
+
+	log.Printf("[HINTS] Replaying hints to %s", target)
+	delivered := 0
+	keys := make(map[string]struct{})
+	defer func() { n.dropDelivered(keys) }()
+	for {
+		hs.mu.Lock()
+		queue := hs.expire(target, time.Now())
+		if len(queue) == 0 {
+			hs.mu.Unlock()
+			log.Printf("[HINTS] Delivered %d hints to %s", delivered, target)
+			return
+		}
+		hint := queue[0]
+		hs.mu.Unlock()
+
+		sendCtx, cancel := context.WithTimeout(ctx, requestTimeout())
+		err := sendWrite(sendCtx, base, hint.Key, hint.Value)
+		cancel()
+		if err != nil {
+			log.Printf("[ERROR] Failed replaying hints to %s after %d: %v", target, delivered, err)
+			return
+		}
+		hs.mu.Lock()
+		// Only this replay removes hints from the front, so the hint sent is
+		// still first unless it expired meanwhile.
+		if q := hs.hints[target]; len(q) > 0 && q[0].Created.Equal(hint.Created) && q[0].Key == hint.Key {
+			hs.hints[target] = q[1:]
+		}
+		hs.mu.Unlock()
+		hs.replayed.Add(1)
+		delivered++
+		keys[hint.Key] = struct{}{}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dropDelivered removes the local copies of delivered keys that this node
+// only held as a stand-in: it does not own them, in the served partition map
+// or on the ring, and keeps no other hints for them.
+func (n *DataNode) dropDelivered(keys map[string]struct{}) {
+	hs := n.hints
+	hs.mu.Lock()
+	for _, queue := range hs.hints {
+		for _, h := range queue {
+			delete(keys, h.Key)
+		}
+	}
+	hs.mu.Unlock()
+
+	dropped := 0
+	for key := range keys {
+		if n.ownsKey(key) {
+			continue
+		}
+		n.mu.Lock()
+		if err := n.store.Delete(key); err == nil {
+			n.updateMerkle(key)
+			dropped++
+		}
+		n.mu.Unlock()
+	}
+	if dropped > 0 {
+		log.Printf("[HINTS] Dropped %d delivered keys this node does not own", dropped)
+	}
+}
+
+// ownsKey reports whether this node replicates key in the served partition
+// map or on the ring's current view, which it moves to on the next rebalance.
+func (n *DataNode) ownsKey(key string) bool {
+	if pm := n.PartitionMap(); pm != nil && slices.Contains(pm.Owners(n.keyToken(key)).Replicas, n.id) {
+		return true
+	}
+	nodes, err := n.placement.GetNodesForKey(key)
+	if err != nil {
+		return true
+	}
+	return slices.ContainsFunc(nodes, func(node hashring.ICacheNode) bool {
+		return node.GetIdentifier() == n.id
+	})
+}
+
+// HintStats returns hint counters and the number of hints pending.
+func (n *DataNode) HintStats() HintStats {
+	hs := n.hints
+	hs.mu.Lock()
+	pending := 0
+	for _, q := range hs.hints {
+		pending += len(q)
+	}
+	hs.mu.Unlock()
+
+	return HintStats{
+		Pending:  pending,
+		Stored:   hs.stored.Load(),
+		Replayed: hs.replayed.Load(),
+		Expired:  hs.expired.Load(),
+		Dropped:  hs.dropped.Load(),
+	}
+}
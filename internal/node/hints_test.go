@@ -0,0 +1,92 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/storage"
+)
+
+func TestReplayHintsGivesUpOnHungOwner(t *testing.T) {
+	n := newTestNode(t, "node-a")
+	config.ConfigObj.Cluster.RequestTimeoutMs = 50
+	hung := make(chan struct{})
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(owner.Close)
+	t.Cleanup(func() { close(hung) })
+	n.StoreHint("node-b", "key", conflict.VersionedValue{Value: []byte("value"), Clock: conflict.VectorClock{"node-a": 1}})
+
+	for range 2 {
+		done := make(chan struct{})
+		go func() {
+			n.ReplayHints(context.Background(), "node-b", owner.URL)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("replay to a hung owner did not give up")
+		}
+	}
+	if st := n.HintStats(); st.Pending != 1 || st.Replayed != 0 {
+		t.Errorf("after failed replays %d hints pending and %d replayed, want 1 and 0", st.Pending, st.Replayed)
+	}
+}
+
+func TestHintTargetsListsOwnersWithHints(t *testing.T) {
+	n := newTestNode(t, "node-a")
+	value := conflict.VersionedValue{Value: []byte("value"), Clock: conflict.VectorClock{"node-a": 1}}
+	for _, target := range []string{"node-c", "node-b", "node-c"} {
+		n.StoreHint(target, "key", value)
+	}
+	if got := n.HintTargets(); !slices.Equal(got, []string{"node-b", "node-c"}) {
+		t.Errorf("HintTargets() = %v, want [node-b node-c]", got)
+	}
+}
+
+func TestReplayDropsDeliveredKeysNotOwned(t *testing.T) {
+	peers, members := servePeers(t, "node-b")
+	n := newTestNode(t, "node-a", "node-c", "node-d", "node-e")
+	ring, err := n.tokenRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.AddNode(members[0]); err != nil {
+		t.Fatal(err)
+	}
+	owned := findKey(t, ring, func(replicas []string) bool { return slices.Contains(replicas, "node-a") })
+	standIn := findKey(t, ring, func(replicas []string) bool { return !slices.Contains(replicas, "node-a") })
+
+	ctx := context.Background()
+	for _, key := range []string{owned, standIn} {
+		entry := RangeEntry{Key: key, Versions: []conflict.VersionedValue{{Value: []byte("value"), Clock: conflict.VectorClock{"node-x": 1}}}}
+		if err := n.writeReplica(ctx, n.id, "node-b", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n.ReplayHints(ctx, "node-b", members[0].url)
+
+	for _, key := range []string{owned, standIn} {
+		if _, err := peers["node-b"].Get(key); err != nil {
+			t.Errorf("%s not delivered to node-b: %v", key, err)
+		}
+	}
+	if _, err := n.Get(owned); err != nil {
+		t.Errorf("owned key %s dropped after replay: %v", owned, err)
+	}
+	if _, err := n.Get(standIn); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("stand-in copy of %s kept after replay, err %v", standIn, err)
+	}
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"slices"
+
+	"GossamerDB/internal/conflict"
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/merkle"
+)
+
+var (
+	ErrRangeNotOwned = errors.New("token range is not replicated by this node")
+)
+
+// wholeRing is the range of the single tree kept before any partition map is
+// served, or when placement has no token ranges.
+var wholeRing = hashring.TokenRange{}
+
+// merkleTrees holds one Merkle tree per token range this node replicates, so
+// replicas compare exactly the ranges they share. The ranges are the
+// partitions of the served partition map or, until one is served, of the
+// ring's map anti-entropy last followed; before either there is a single tree
+// over the whole ring.
+type merkleTrees struct {
+	pm    *hashring.PartitionMap
+	depth int
+	trees map[hashring.TokenRange]*merkle.Tree
+}
+
+func newMerkleTrees(pm *hashring.PartitionMap, self string, depth int) *merkleTrees {
+	mt := &merkleTrees{pm: pm, depth: depth, trees: make(map[hashring.TokenRange]*merkle.Tree)}
+	if pm == nil {
+		mt.trees[wholeRing] = merkle.NewRangeTree(wholeRing.Start, wholeRing.End, depth)
+		return mt
+	}
+	for _, p := range pm.Partitions {
+		if slices.Contains(p.Replicas, self) {
+			mt.trees[p.Range] = merkle.NewRangeTree(p.Range.Start, p.Range.End, depth)
+		}
+	}
+	return mt
+}
+
+// treeFor returns the tree covering token, or nil if this node does not
+// replicate it.
+func (mt *merkleTrees) treeFor(token uint64) *merkle.Tree {
+	if mt.pm == nil {
+		return mt.trees[wholeRing]
+	}
+	return mt.trees[mt.pm.Owners(token).Range]
+}
+
+// updateMerkle refreshes key's leaf in its range's Merkle tree from the store,
+// after the key was written or deleted. Callers must hold n.mu.
+func (n *DataNode) updateMerkle(key string) {
+	pos := n.keyToken(key)
+	tree := n.merkleTrees.treeFor(pos)
+	if tree == nil {
+		return
+	}
+	versions, _ := n.store.Get(key)
+	if len(versions) == 0 {
+		tree.Remove(pos, key)
+		return
+	}
+	tree.Update(pos, key, versionsDigest(versions))
+}
+
+// versionsDigest is the leaf digest of a key: a hash of the canonical encoding
+// of all its sibling versions, so equal digests mean the replicas hold the
+// same values, clocks and tombstones.
+func versionsDigest(versions []conflict.VersionedValue) merkle.Digest {
+	return sha256.Sum256(conflict.CanonicalEncoding(versions))
+}
+
+// keyToken places key in the hash space: at its ring token when placement is
+// a hash ring, so Merkle buckets line up with token ranges, and at a hash of
+// the key otherwise.
+func (n *DataNode) keyToken(key string) uint64 {
+	if ring, err := n.tokenRing(); err == nil {
+		if token, err := ring.Token(key); err == nil {
+			return token
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// resetMerkleTrees rebuilds the trees for the ranges pm assigns to this node.
+// It runs when the served partition map changes and scans the whole store.
+func (n *DataNode) resetMerkleTrees(pm *hashring.PartitionMap) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.merkleTrees = newMerkleTrees(pm, n.id, n.merkleTrees.depth)
+	for _, key := range n.store.ListKeys() {
+		n.updateMerkle(key)
+	}
+}
+
+// MerkleRanges returns the token ranges this node keeps Merkle trees for.
+func (n *DataNode) MerkleRanges() []hashring.TokenRange {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	ranges := make([]hashring.TokenRange, 0, len(n.merkleTrees.trees))
+	for r := range n.merkleTrees.trees {
+		ranges = append(ranges, r)
+	}
+	slices.SortFunc(ranges, func(a, b hashring.TokenRange) int {
+		switch {
+		case a.End < b.End:
+			return -1
+		case a.End > b.End:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return ranges
+}
+
+// SharedRanges returns the token ranges both this node and peer replicate,
+// which are the ranges whose Merkle trees the two can compare.
+func (n *DataNode) SharedRanges(peer string) []hashring.TokenRange {
+	n.mu.RLock()
+	pm := n.merkleTrees.pm
+	n.mu.RUnlock()
+	if pm == nil {
+		return []hashring.TokenRange{wholeRing}
+	}
+
+	ranges := make([]hashring.TokenRange, 0)
+	for _, p := range pm.Partitions {
+		if slices.Contains(p.Replicas, n.id) && slices.Contains(p.Replicas, peer) {
+			ranges = append(ranges, p.Range)
+		}
+	}
+	return ranges
+}
+
+// GetMerkleRoot returns the hex root hash of a range's tree for anti-entropy
+// comparison.
+func (n *DataNode) GetMerkleRoot(r hashring.TokenRange) (string, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	tree, ok := n.merkleTrees.trees[r]
+	if !ok {
+		return "", ErrRangeNotOwned
+	}
+	return tree.RootHash(), nil
+}
@@ -0,0 +1,224 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/merkle"
+)
+
+var (
+	ErrMerkleShape = errors.New("peer Merkle tree has a different shape")
+)
+
+// MerkleRoot describes a range's tree to a peer about to compare with it.
+type MerkleRoot struct {
+	Root  string `json:"root"`  // Hex hash of the root
+	Depth int    `json:"depth"` // Levels below the root
+}
+
+// merkleTree returns the tree of a range this node replicates.
+func (n *DataNode) merkleTree(r hashring.TokenRange) (*merkle.Tree, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	tree, ok := n.merkleTrees.trees[r]
+	if !ok {
+		return nil, ErrRangeNotOwned
+	}
+	return tree, nil
+}
+
+// MerkleRootInfo returns the root hash and depth of a range's tree.
+func (n *DataNode) MerkleRootInfo(r hashring.TokenRange) (MerkleRoot, error) {
+	tree, err := n.merkleTree(r)
+	if err != nil {
+		return MerkleRoot{}, err
+	}
+	return MerkleRoot{Root: tree.RootHash(), Depth: tree.Depth}, nil
+}
+
+// MerkleHashes returns the hashes of the given nodes of a range's tree.
+func (n *DataNode) MerkleHashes(r hashring.TokenRange, indices []int) ([]string, error) {
+	tree, err := n.merkleTree(r)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Hashes(indices)
+}
+
+// MerkleLeaves returns the key digests of the given leaves of a range's tree,
+// hex encoded.
+func (n *DataNode) MerkleLeaves(r hashring.TokenRange, leaves []int) ([]map[string]string, error) {
+	tree, err := n.merkleTree(r)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := tree.LeafDigests(leaves)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]string, len(digests))
+	for i, keys := range digests {
+		out[i] = make(map[string]string, len(keys))
+		for k, d := range keys {
+			out[i][k] = hex.EncodeToString(d[:])
+		}
+	}
+	return out, nil
+}
+
+// DiffWithPeer compares a range's tree with the peer's tree for the same
+// range over the network. Only the root is fetched when the trees match;
+// otherwise the comparison descends level by level, fetching just the
+// children of differing nodes, and then the key digests of the differing
+// leaves. It returns the keys this node is missing, the keys only it holds,
+// and the keys whose versions differ.
+func (n *DataNode) DiffWithPeer(ctx context.Context, peer string, r hashring.TokenRange) (merkle.KeyDiff, error) {
+	tree, err := n.merkleTree(r)
+	if err != nil {
+		return merkle.KeyDiff{}, err
+	}
+	base, err := n.dataURL(peer)
+	if err != nil {
+		return merkle.KeyDiff{}, err
+	}
+
+	var root MerkleRoot
+	if err := getJSON(ctx, base+"/internal/merkle/root?"+rangeQuery(r).Encode(), &root); err != nil {
+		return merkle.KeyDiff{}, fmt.Errorf("fetching Merkle root of %s from %s: %w", r, peer, err)
+	}
+	if root.Depth != tree.Depth {
+		return merkle.KeyDiff{}, fmt.Errorf("%w: depth %d, local depth %d", ErrMerkleShape, root.Depth, tree.Depth)
+	}
+
+	diff, err := tree.DiffRemoteKeys(
+		func(indices []int) ([]string, error) {
+			if len(indices) == 1 && indices[0] == 1 {
+				return []string{root.Root}, nil
+			}
+			return fetchMerkleHashes(ctx, base, r, indices)
+		},
+		func(leaves []int) ([]map[string]merkle.Digest, error) {
+			return fetchMerkleLeaves(ctx, base, r, leaves)
+		},
+	)
+	if err != nil {
+		return merkle.KeyDiff{}, fmt.Errorf("diffing Merkle tree of %s with %s: %w", r, peer, err)
+	}
+	return diff, nil
+}
+
+// merkleHashesRequest asks a peer for the hashes of some nodes of a tree.
+type merkleHashesRequest struct {
+	Indices []int `json:"indices"` // Heap indices: root is 1, children of i are 2i and 2i+1
+}
+
+type merkleHashesResponse struct {
+	Hashes []string `json:"hashes"`
+}
+
+// merkleLeavesResponse carries the hex key digests of some leaves of a tree.
+type merkleLeavesResponse struct {
+	Leaves []map[string]string `json:"leaves"`
+}
+
+// merkleLeafBatch is how many leaves are fetched per request, bounding the
+// size of each response.
+const merkleLeafBatch = 64
+
+func fetchMerkleHashes(ctx context.Context, base string, r hashring.TokenRange, indices []int) ([]string, error) {
+	var out merkleHashesResponse
+	if err := postJSON(ctx, base+"/internal/merkle/hashes?"+rangeQuery(r).Encode(), merkleHashesRequest{Indices: indices}, &out); err != nil {
+		return nil, err
+	}
+	return out.Hashes, nil
+}
+
+// fetchMerkleLeaves fetches the key digests of leaves of the peer's tree, in
+// batches of merkleLeafBatch.
+func fetchMerkleLeaves(ctx context.Context, base string, r hashring.TokenRange, leaves []int) ([]map[string]merkle.Digest, error) {
+	out := make([]map[string]merkle.Digest, 0, len(leaves))
+	for batch := range slices.Chunk(leaves, merkleLeafBatch) {
+		var resp merkleLeavesResponse
+		if err := postJSON(ctx, base+"/internal/merkle/leaves?"+rangeQuery(r).Encode(), merkleHashesRequest{Indices: batch}, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Leaves) != len(batch) {
+			return nil, fmt.Errorf("peer returned %d leaves for %d requested", len(resp.Leaves), len(batch))
+		}
+		for _, keys := range resp.Leaves {
+			digests := make(map[string]merkle.Digest, len(keys))
+			for k, h := range keys {
+				var d merkle.Digest
+				if len(h) != hex.EncodedLen(len(d)) {
+					return nil, fmt.Errorf("invalid digest of %q", k)
+				}
+				if _, err := hex.Decode(d[:], []byte(h)); err != nil {
+					return nil, fmt.Errorf("invalid digest of %q: %w", k, err)
+				}
+				digests[k] = d
+			}
+			out = append(out, digests)
+		}
+	}
+	return out, nil
+}
+
+// postJSON posts body to u as JSON and decodes the response into out, unless
+// out is nil.
+func postJSON(ctx context.Context, u string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func rangeQuery(r hashring.TokenRange) url.Values {
+	q := url.Values{}
+	q.Set("start", strconv.FormatUint(r.Start, 10))
+	q.Set("end", strconv.FormatUint(r.End, 10))
+	return q
+}
@@ -0,0 +1,200 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/merkle"
+)
+
+var (
+	ErrStaleSnapshot = errors.New("merkle snapshot does not match the store")
+)
+
+// merkleSnapshotFile is the name of the Merkle snapshot under the persistence
+// path.
+const merkleSnapshotFile = "merkle.snapshot.json"
+
+// merkleSnapshot is the persisted form of a node's Merkle trees. Store ties it
+// to the store's contents: the trees are only valid for a store holding
+// exactly the keys and versions they were built from. The partition map the
+// trees' ranges come from is kept with them, so they can be restored before
+// the node learns the ring again.
+type merkleSnapshot struct {
+	Store        string                 `json:"store"`        // Hex checksum of the store contents the trees reflect
+	PartitionMap *hashring.PartitionMap `json:"partitionMap"` // Map the ranges come from, nil for a single tree over the ring
+	Served       bool                   `json:"served"`       // Whether PartitionMap is the map the node served
+	Depth        int                    `json:"depth"`        // Depth of every tree
+	Trees        []merkle.Snapshot      `json:"trees"`        // One per replicated range
+	Checksum     string                 `json:"checksum"`     // SHA-256 of the fields above
+}
+
+// MerkleSnapshotPath returns where the Merkle snapshot is kept under the
+// persistence directory dir.
+func MerkleSnapshotPath(dir string) string {
+	return filepath.Join(dir, merkleSnapshotFile)
+}
+
+// SaveMerkleTrees writes the Merkle trees to path, tagged with the checksum
+// of the store's current contents. The file is replaced atomically, so a
+// crash while saving leaves the previous snapshot in place.
+func (n *DataNode) SaveMerkleTrees(path string) error {
+	served := n.PartitionMap()
+	n.mu.RLock()
+	sum := n.store.Checksum()
+	snap := merkleSnapshot{
+		Store:        hex.EncodeToString(sum[:]),
+		PartitionMap: n.merkleTrees.pm,
+		Served:       served != nil && served == n.merkleTrees.pm,
+		Depth:        n.merkleTrees.depth,
+		Trees:        make([]merkle.Snapshot, 0, len(n.merkleTrees.trees)),
+	}
+	for _, tree := range n.merkleTrees.trees {
+		snap.Trees = append(snap.Trees, tree.Snapshot())
+	}
+	n.mu.RUnlock()
+
+	checksum, err := checksumSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	snap.Checksum = checksum
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	log.Printf("[MERKLE] Saved %d trees to %s", len(snap.Trees), path)
+	return nil
+}
+
+// LoadMerkleTrees restores the Merkle trees saved at path, which is much
+// cheaper than rescanning a large store. The trees are rebuilt from the store
+// instead when there is no snapshot, when it is corrupt, or when it is stale:
+// taken of other store contents or with another depth. A snapshot of the
+// served partition map serves it again. It reports whether the snapshot was
+// used.
+//
+// Only a durable store survives a restart with the contents a snapshot was
+// taken of, so nothing loads or saves snapshots over the in-memory store.
+func (n *DataNode) LoadMerkleTrees(path string) bool {
+	snap, err := readMerkleSnapshot(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("[MERKLE] No snapshot at %s, rebuilding trees", path)
+		n.rebuildMerkleTrees()
+		return false
+	case err != nil:
+		log.Printf("[MERKLE] Discarding snapshot at %s: %v", path, err)
+		n.rebuildMerkleTrees()
+		return false
+	}
+
+	n.mu.Lock()
+	err = n.installMerkleSnapshot(snap)
+	n.mu.Unlock()
+	if err != nil {
+		log.Printf("[MERKLE] Discarding snapshot at %s: %v", path, err)
+		n.rebuildMerkleTrees()
+		return false
+	}
+	if snap.Served {
+		n.partitionMu.Lock()
+		if n.partitionMap == nil {
+			n.partitionMap = snap.PartitionMap
+		}
+		n.partitionMu.Unlock()
+	}
+	log.Printf("[MERKLE] Loaded %d trees from %s", len(snap.Trees), path)
+	return true
+}
+
+// installMerkleSnapshot replaces the trees with the snapshot's if it matches
+// the store's contents and covers exactly the ranges its partition map
+// assigns to this node. Callers must hold n.mu.
+func (n *DataNode) installMerkleSnapshot(snap merkleSnapshot) error {
+	if sum := n.store.Checksum(); snap.Store != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("%w: snapshot store checksum %s, store checksum %x", ErrStaleSnapshot, snap.Store, sum)
+	}
+	if snap.Depth != n.merkleTrees.depth {
+		return fmt.Errorf("%w: snapshot depth %d, configured depth %d", ErrStaleSnapshot, snap.Depth, n.merkleTrees.depth)
+	}
+	want := newMerkleTrees(snap.PartitionMap, n.id, snap.Depth)
+	if len(snap.Trees) != len(want.trees) {
+		return fmt.Errorf("%w: snapshot has %d ranges, its partition map assigns %d", merkle.ErrCorruptSnapshot, len(snap.Trees), len(want.trees))
+	}
+
+	trees := make(map[hashring.TokenRange]*merkle.Tree, len(snap.Trees))
+	for _, ts := range snap.Trees {
+		r := hashring.TokenRange{Start: ts.Start, End: ts.End}
+		if _, ok := want.trees[r]; !ok {
+			return fmt.Errorf("%w: range %s is not assigned to this node", merkle.ErrCorruptSnapshot, r)
+		}
+		if ts.Depth != snap.Depth {
+			return fmt.Errorf("%w: tree of %s has depth %d", merkle.ErrCorruptSnapshot, r, ts.Depth)
+		}
+		tree, err := merkle.FromSnapshot(ts)
+		if err != nil {
+			return err
+		}
+		trees[r] = tree
+	}
+	want.trees = trees
+	n.merkleTrees = want
+	return nil
+}
+
+// rebuildMerkleTrees recomputes the trees for the ranges currently served.
+func (n *DataNode) rebuildMerkleTrees() {
+	n.mu.RLock()
+	pm := n.merkleTrees.pm
+	n.mu.RUnlock()
+	n.resetMerkleTrees(pm)
+}
+
+func readMerkleSnapshot(path string) (merkleSnapshot, error) {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return merkleSnapshot{}, err
+	}
+	var snap merkleSnapshot
+	if err := json.Unmarshal(payload, &snap); err != nil {
+		return merkleSnapshot{}, fmt.Errorf("%w: %v", merkle.ErrCorruptSnapshot, err)
+	}
+	sum, err := checksumSnapshot(snap)
+	if err != nil {
+		return merkleSnapshot{}, err
+	}
+	if sum != snap.Checksum {
+		return merkleSnapshot{}, fmt.Errorf("%w: %v", merkle.ErrCorruptSnapshot, ErrChecksumMismatch)
+	}
+	return snap, nil
+}
+
+// checksumSnapshot hashes everything in a snapshot but its checksum.
+func checksumSnapshot(snap merkleSnapshot) (string, error) {
+	snap.Checksum = ""
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestMerkleSnapshotMatchesStoreContents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), merkleSnapshotFile)
+	write := func(n *DataNode) {
+		for i := range 500 {
+			if err := n.Put(fmt.Sprintf("key-%d", i), []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	saved := newTestNode(t, "node-a", "node-b", "node-c")
+	saved.replicaRanges()
+	write(saved)
+	if err := saved.SaveMerkleTrees(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restarted node whose store lost its contents must rebuild.
+	if empty := newTestNode(t, "node-a", "node-b", "node-c"); empty.LoadMerkleTrees(path) {
+		t.Error("snapshot loaded into an empty store")
+	}
+
+	// One holding the same contents gets the saved ranges and roots back.
+	same := newTestNode(t, "node-a", "node-b", "node-c")
+	write(same)
+	if !same.LoadMerkleTrees(path) {
+		t.Fatal("snapshot rejected by a store with the same contents")
+	}
+	ranges := saved.MerkleRanges()
+	if got := same.MerkleRanges(); len(got) != len(ranges) {
+		t.Fatalf("restored %d ranges, saved %d", len(got), len(ranges))
+	}
+	for _, r := range ranges {
+		want, _ := saved.GetMerkleRoot(r)
+		got, err := same.GetMerkleRoot(r)
+		if err != nil || got != want {
+			t.Errorf("root of %s is %s (%v), want %s", r, got, err, want)
+		}
+	}
+
+	// Any further write makes the snapshot stale.
+	if err := same.Put("key-0", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if same.LoadMerkleTrees(path) {
+		t.Error("snapshot loaded after the store changed")
+	}
+}
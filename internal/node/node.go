@@ -1,12 +1,12 @@
 package node
 
 import (
-	"sort"
 	"sync"
+	"sync/atomic"
 
 	"GossamerDB/internal/config"
 	"GossamerDB/internal/conflict"
-	"GossamerDB/internal/merkle"
+	"GossamerDB/internal/hashring"
 	"GossamerDB/internal/quorum"
 	"GossamerDB/internal/storage"
 )
@@ -15,58 +15,133 @@ import (
 type DataNode struct {
 	id          string
 	store       storage.Store
-	merkleTree  *merkle.Tree
+	merkleTrees *merkleTrees
 	quorum      *quorum.Quorum
 	vectorClock *conflict.VectorClock
+	resolver    conflict.ConflictResolver
+	placement   hashring.Placement
+	isUp        func(id string) bool // failure detector, nil until gossip starts
 
 	mu sync.RWMutex
 
+	// requests counts reads and writes served, for load reporting.
+	requests atomic.Uint64
+
+	// partitionMap is the ownership this node currently serves. It only moves
+	// to the ring's latest view when a rebalance is applied.
+	partitionMap *hashring.PartitionMap
+	partitionMu  sync.RWMutex
+	rebalanceMu  sync.Mutex
+	rebalance    rebalanceJob
+
+	// handoffs holds the resume cursor of incoming range streams; outgoing
+	// holds, per range being handed off, the nodes writes are mirrored to.
+	handoffs  map[handoffKey]string
+	outgoing  map[hashring.TokenRange]map[string]*dualWrite
+	handoffMu sync.Mutex
+
+	// hints holds writes accepted on behalf of owners that were down.
+	hints *hintStore
+
+	// antiEntropy counts the work of background repair.
+	antiEntropy antiEntropy
+	// readRepairs counts replicas repaired by coordinated reads.
+	readRepairs readRepairs
+
 	// Additional fields for membership, gossip, repair can be added here
 }
 
-// NewDataNode constructs a new node with specified config.
-func NewDataNode() (*DataNode, error) {
+// NewDataNode constructs a new node with specified config, placing keys with placement.
+func NewDataNode(placement hashring.Placement) (*DataNode, error) {
 	q := quorum.New()
 	cfg := config.ConfigObj
 	return &DataNode{
 		id:          config.SelfID,
 		store:       storage.NewMemoryStore(cfg.VectorClock.MaxVersionsPerKey),
 		quorum:      q,
-		merkleTree:  merkle.NewTree(cfg.MerkleTree.BucketSize),
+		merkleTrees: newMerkleTrees(nil, config.SelfID, cfg.MerkleTree.Depth),
 		vectorClock: conflict.NewVectorClock(),
+		resolver:    conflict.InitResolver(cfg.VectorClock.MaxVersionsPerKey),
+		placement:   placement,
+		handoffs:    make(map[handoffKey]string),
+		outgoing:    make(map[hashring.TokenRange]map[string]*dualWrite),
+		hints:       newHintStore(),
 	}, nil
 }
 
-// Delete removes a key and updates Merkle tree
+// SetFailureDetector sets how the node learns whether peers are up, which
+// decides when stand-ins replace owners in preference lists.
+func (n *DataNode) SetFailureDetector(isUp func(id string) bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.isUp = isUp
+}
+
+// PreferenceList returns the nodes that should hold key right now: its healthy
+// owners, stand-ins for owners that are down, and further fallbacks.
+func (n *DataNode) PreferenceList(key string) (*hashring.PreferenceList, error) {
+	n.mu.RLock()
+	isUp := n.isUp
+	n.mu.RUnlock()
+	return n.placement.PreferenceList(key, isUp)
+}
+
+// Delete writes a tombstone for key: a version without a value that
+// supersedes the versions this node has seen. Replicas and anti-entropy pass
+// it on like any other version, so the delete spreads instead of the old value
+// being copied back.
 func (n *DataNode) Delete(key string) error {
+	n.requests.Add(1)
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	err := n.store.Delete(key)
-	if err != nil {
+	if versions, err := n.store.Get(key); err == nil {
+		for _, v := range versions {
+			*n.vectorClock = n.vectorClock.Merge(v.Clock)
+		}
+	}
+	n.vectorClock.Increment(n.id)
+	tombstone := conflict.VersionedValue{Clock: n.vectorClock.Copy()}
+	if err := n.store.Set(key, tombstone); err != nil {
 		return err
 	}
-	n.rebuildMerkleTree() // optamize this to reduce latency of delete
+	n.updateMerkle(key)
+	n.forwardWrite(key, tombstone)
 	return nil
 }
 
 // Put stores a value for a key, increments vector clock, triggers Merkle update.
 func (n *DataNode) Put(key string, value []byte) error {
+	n.requests.Add(1)
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
 	n.vectorClock.Increment(n.id)
 
 	vv := conflict.VersionedValue{
-		Value: value,
+		Value: liveValue(value),
 		Clock: n.vectorClock.Copy(),
 	}
-	n.rebuildMerkleTree() // optamize this to reduce latency of put
-	return n.store.Set(key, vv)
+	if err := n.store.Set(key, vv); err != nil {
+		return err
+	}
+	n.updateMerkle(key)
+	n.forwardWrite(key, vv)
+	return nil
+}
+
+// liveValue returns value, made non-nil so that writing it does not read as a
+// tombstone.
+func liveValue(value []byte) []byte {
+	if value == nil {
+		return []byte{}
+	}
+	return value
 }
 
-// Get returns versions for a key.
+// Get returns versions for a key, tombstones included.
 func (n *DataNode) Get(key string) ([]conflict.VersionedValue, error) {
+	n.requests.Add(1)
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
@@ -81,32 +156,7 @@ func (n *DataNode) ListKeys() []string {
 	return n.store.ListKeys()
 }
 
-// --- Merkle integration ---
-
-// Rebuild (or update) the Merkle tree from store (called after each mutation)
-func (n *DataNode) rebuildMerkleTree() {
-	keys := n.store.ListKeys()
-	sort.Strings(keys)
-	kvs := map[string][]byte{}
-	for _, k := range keys {
-		versions, _ := n.store.Get(k)
-		if len(versions) > 0 {
-			kvs[k] = versions[0].Value // If you keep multiple values, you can hash/concat all
-		}
-	}
-	n.merkleTree.Build(keys, kvs)
-}
-
-// GetMerkleRoot returns the hex root hash for anti-entropy comparison.
-func (n *DataNode) GetMerkleRoot() string {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-	return n.merkleTree.RootHash()
-}
-
-// DiffMerkle compares with another tree (from a peer), returns differing key ranges to repair.
-func (n *DataNode) DiffMerkle(peerRoot *merkle.Tree) ([][]string, error) {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-	return n.merkleTree.Diff(peerRoot)
+// RequestCount returns how many reads and writes this node has served.
+func (n *DataNode) RequestCount() uint64 {
+	return n.requests.Load()
 }
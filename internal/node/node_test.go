@@ -0,0 +1,69 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/hashring"
+)
+
+func TestMain(m *testing.M) {
+	// Every write logs its vector clock, which would swamp the output.
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testMember is a placement node for tests.
+type testMember string
+
+func (m testMember) GetIdentifier() string { return string(m) }
+
+// newTestNode returns a data node named self over a ring holding it and the
+// given peers.
+func newTestNode(tb testing.TB, self string, peers ...string) *DataNode {
+	tb.Helper()
+	if err := config.Load(""); err != nil {
+		tb.Fatal(err)
+	}
+	config.SelfID = self
+	ring := hashring.InitHashRing(hashring.SetVirtualNodes(16), hashring.SetReplicationFactor(config.ConfigObj.Cluster.TotalReplicas))
+	for _, id := range append([]string{self}, peers...) {
+		if err := ring.AddNode(testMember(id)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	n, err := NewDataNode(ring)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return n
+}
+
+// BenchmarkPut writes to stores of growing size. The time per write stays
+// flat, since a write only rehashes its key's Merkle leaf and path.
+func BenchmarkPut(b *testing.B) {
+	for _, size := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			n := newTestNode(b, "node-a")
+			keys := make([]string, size)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key-%d", i)
+				if err := n.Put(keys[i], []byte("value")); err != nil {
+					b.Fatal(err)
+				}
+			}
+			value := []byte("updated")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := n.Put(keys[i%len(keys)], value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
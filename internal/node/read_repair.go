@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/conflict"
+)
+
+// readRepairTimeout bounds background repairs, which outlive the read that
+// started them.
+const readRepairTimeout = 5 * time.Second
+
+// replicaRead is what one replica returned for a key during a coordinated
+// read.
+type replicaRead struct {
+	node     string
+	versions []conflict.VersionedValue
+}
+
+// ReadRepairStats counts read repairs since the node started.
+type ReadRepairStats struct {
+	Divergent uint64 `json:"divergent"` // Reads whose replicas returned different versions
+	Skipped   uint64 `json:"skipped"`   // Divergent reads left unrepaired by the read repair probability
+	Repaired  uint64 `json:"repaired"`  // Stale replicas brought up to date
+	Failed    uint64 `json:"failed"`    // Stale replicas that could not be repaired
+}
+
+type readRepairs struct {
+	divergent atomic.Uint64
+	skipped   atomic.Uint64
+	repaired  atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// resolveReads merges the versions every replica returned for a key with the
+// conflict resolver. Versions several replicas hold are counted once.
+func (n *DataNode) resolveReads(reads []replicaRead) []conflict.VersionedValue {
+	seen := make(map[string]struct{})
+	all := make([]conflict.VersionedValue, 0)
+	for _, r := range reads {
+		for _, v := range r.versions {
+			enc := string(v.AppendCanonical(nil))
+			if _, ok := seen[enc]; ok {
+				continue
+			}
+			seen[enc] = struct{}{}
+			all = append(all, v)
+		}
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	return n.resolver.Resolve(all)
+}
+
+// readRepair writes the merged versions of key back to the replicas whose
+// reads differ from them. In blocking mode it returns once every stale replica
+// was written; in background mode it returns at once. Only the configured
+// share of divergent reads is repaired, leaving the rest to anti-entropy.
+func (n *DataNode) readRepair(ctx context.Context, key string, reads []replicaRead, merged []conflict.VersionedValue) {
+	if len(merged) == 0 {
+		return
+	}
+	stale := make([]string, 0)
+	for _, r := range reads {
+		if !sameVersions(r.versions, merged) {
+			stale = append(stale, r.node)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+	n.readRepairs.divergent.Add(1)
+
+	cfg := config.ConfigObj.Repair
+	if !cfg.Enabled || rand.Float64() >= cfg.ReadRepairProbability {
+		n.readRepairs.skipped.Add(1)
+		return
+	}
+	if cfg.ReadRepairMode == config.ReadRepairBlocking {
+		n.repairReplicas(ctx, key, stale, merged)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), readRepairTimeout)
+		defer cancel()
+		n.repairReplicas(ctx, key, stale, merged)
+	}()
+}
+
+// repairReplicas writes versions of key to every node in stale, in parallel.
+// Each replica merges them with what it holds through its conflict resolver.
+func (n *DataNode) repairReplicas(ctx context.Context, key string, stale []string, versions []conflict.VersionedValue) {
+	var wg sync.WaitGroup
+	for _, id := range stale {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.writeReplica(ctx, id, "", RangeEntry{Key: key, Versions: versions}); err != nil {
+				n.readRepairs.failed.Add(1)
+				log.Printf("[ERROR] Read repair of %q on %s failed: %v", key, id, err)
+				return
+			}
+			n.readRepairs.repaired.Add(1)
+		}()
+	}
+	wg.Wait()
+}
+
+// writeReplica merges entry into the replica id, which may be this node. When
+// id stands in for the owner hintFor, it also keeps the versions as hints to
+// hand back to the owner.
+func (n *DataNode) writeReplica(ctx context.Context, id, hintFor string, entry RangeEntry) error {
+	if id == n.id {
+		if err := n.ApplyVersions(entry.Key, entry.Versions); err != nil {
+			return err
+		}
+		if hintFor != "" {
+			for _, v := range entry.Versions {
+				n.StoreHint(hintFor, entry.Key, v)
+			}
+		}
+		return nil
+	}
+	base, err := n.dataURL(id)
+	if err != nil {
+		return err
+	}
+	if hintFor != "" {
+		_, err = sendHintedEntry(ctx, base, hintFor, entry)
+	} else {
+		_, err = sendEntry(ctx, base, entry)
+	}
+	return err
+}
+
+// ReadRepairStats returns read repair counters.
+func (n *DataNode) ReadRepairStats() ReadRepairStats {
+	return ReadRepairStats{
+		Divergent: n.readRepairs.divergent.Load(),
+		Skipped:   n.readRepairs.skipped.Load(),
+		Repaired:  n.readRepairs.repaired.Load(),
+		Failed:    n.readRepairs.failed.Load(),
+	}
+}
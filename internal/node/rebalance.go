@@ -0,0 +1,226 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"GossamerDB/internal/hashring"
+)
+
+var (
+	ErrStalePlan     = errors.New("ring changed since the plan was made")
+	ErrNoTokenRanges = errors.New("placement algorithm has no token ranges")
+	ErrRebalancing   = errors.New("a rebalance is already running")
+
+	ErrHandoffNotCompleted = errors.New("source still dual-writing after the cutover")
+)
+
+// RebalanceState is where a rebalance started with StartRebalance is at.
+type RebalanceState string
+
+const (
+	// RebalanceRunning means ranges are still streaming or being cut over.
+	RebalanceRunning RebalanceState = "running"
+	// RebalanceDone means the new partition map is served.
+	RebalanceDone RebalanceState = "done"
+	// RebalanceFailed means the rebalance stopped; it can be started again.
+	RebalanceFailed RebalanceState = "failed"
+)
+
+// RebalanceStatus reports on the last rebalance started on this node.
+type RebalanceStatus struct {
+	Epoch        uint64                 `json:"epoch"`                  // Epoch of the plan being applied
+	State        RebalanceState         `json:"state"`                  // Where the rebalance is at
+	Error        string                 `json:"error,omitempty"`        // Why it failed, or what went wrong after the cutover
+	Started      time.Time              `json:"started"`                // When it started
+	Finished     time.Time              `json:"finished"`               // When it finished, zero while running
+	PartitionMap *hashring.PartitionMap `json:"partitionMap,omitempty"` // Map served once done
+}
+
+// rebalanceJob holds the status of the last rebalance started.
+type rebalanceJob struct {
+	mu     sync.Mutex
+	status *RebalanceStatus
+}
+
+// tokenRing returns the placement as a hash ring. Partition maps, rebalancing
+// and range handoff work on token ranges, which only consistent hashing has.
+func (n *DataNode) tokenRing() (*hashring.HashRing, error) {
+	ring, ok := n.placement.(*hashring.HashRing)
+	if !ok {
+		return nil, ErrNoTokenRanges
+	}
+	return ring, nil
+}
+
+// PartitionMap returns the partition map this node currently serves, or nil
+// if none was applied yet.
+func (n *DataNode) PartitionMap() *hashring.PartitionMap {
+	n.partitionMu.RLock()
+	defer n.partitionMu.RUnlock()
+	return n.partitionMap
+}
+
+// PlanRebalance compares the served partition map with the ring's current
+// view and returns the transfers needed to switch over, without applying
+// anything. Transfer sizes are estimated from this node's store statistics.
+func (n *DataNode) PlanRebalance() (*hashring.RebalancePlan, error) {
+	ring, err := n.tokenRing()
+	if err != nil {
+		return nil, err
+	}
+	n.partitionMu.RLock()
+	current := n.partitionMap
+	n.partitionMu.RUnlock()
+
+	next, err := ring.PartitionMap(current)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		// Nothing is served yet, so there is no data to move.
+		return &hashring.RebalancePlan{ToEpoch: next.Epoch, Transfers: []hashring.Transfer{}, Unsourced: []hashring.Transfer{}}, nil
+	}
+	stats := n.store.Stats()
+	return hashring.PlanRebalance(current, next, hashring.UniformSizer(stats.Bytes, current.Share(n.id))), nil
+}
+
+// StartRebalance applies the plan for epoch in the background, so streaming
+// does not depend on the connection of whoever asked for it. It fails at once
+// with ErrStalePlan if the ring already moved past epoch, and with
+// ErrRebalancing if a rebalance is still running. RebalanceStatus reports the
+// outcome.
+func (n *DataNode) StartRebalance(epoch uint64) (RebalanceStatus, error) {
+	plan, err := n.PlanRebalance()
+	if err != nil {
+		return RebalanceStatus{}, err
+	}
+	if plan.ToEpoch != epoch {
+		return RebalanceStatus{}, fmt.Errorf("%w: plan is for epoch %d, ring is at %d", ErrStalePlan, epoch, plan.ToEpoch)
+	}
+
+	job := &n.rebalance
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.status != nil && job.status.State == RebalanceRunning {
+		return *job.status, ErrRebalancing
+	}
+	status := &RebalanceStatus{Epoch: epoch, State: RebalanceRunning, Started: time.Now()}
+	job.status = status
+	go func() {
+		pm, err := n.ApplyRebalance(context.Background(), epoch)
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		status.Finished = time.Now()
+		if err != nil && pm == nil {
+			status.State = RebalanceFailed
+			status.Error = err.Error()
+			log.Printf("[ERROR] Rebalance to epoch %d failed: %v", epoch, err)
+			return
+		}
+		status.State = RebalanceDone
+		status.PartitionMap = pm
+		if err != nil {
+			status.Error = err.Error()
+		}
+	}()
+	return *status, nil
+}
+
+// RebalanceStatus returns the status of the last rebalance started, and false
+// if none was.
+func (n *DataNode) RebalanceStatus() (RebalanceStatus, bool) {
+	n.rebalance.mu.Lock()
+	defer n.rebalance.mu.Unlock()
+	if n.rebalance.status == nil {
+		return RebalanceStatus{}, false
+	}
+	return *n.rebalance.status, true
+}
+
+// ApplyRebalance switches the served partition map to the ring's current
+// view. epoch must be the ToEpoch of the plan the operator reviewed; if the
+// ring moved on since, ErrStalePlan is returned and nothing changes. Ranges
+// this node gains are streamed from their old owners first, which dual-write
+// to this node until the new map is in place: each batch renews the source's
+// dual-write lease, and ranges already streamed are renewed until the cutover.
+// A failed stream can be resumed by applying the same epoch again; if the
+// leases lapsed meanwhile, the sources stop dual-writing and the streams start
+// over. If a source cannot be told the transfer is complete, the new map is
+// still served and returned, together with an error wrapping
+// ErrHandoffNotCompleted; the source stops when its lease lapses.
+func (n *DataNode) ApplyRebalance(ctx context.Context, epoch uint64) (*hashring.PartitionMap, error) {
+	n.rebalanceMu.Lock()
+	defer n.rebalanceMu.Unlock()
+
+	plan, err := n.PlanRebalance()
+	if err != nil {
+		return nil, err
+	}
+	if plan.ToEpoch != epoch {
+		return nil, fmt.Errorf("%w: plan is for epoch %d, ring is at %d", ErrStalePlan, epoch, plan.ToEpoch)
+	}
+	incoming := make([]hashring.Transfer, 0)
+	for _, t := range plan.Transfers {
+		if t.Destination == n.id {
+			incoming = append(incoming, t)
+		}
+	}
+	for _, t := range plan.Unsourced {
+		if t.Destination == n.id {
+			log.Printf("[REBALANCE] No remaining node holds %s, taking it over empty", t.Range)
+		}
+	}
+	keepCtx, cancel := context.WithCancel(ctx)
+	streamed := make(chan hashring.Transfer, len(incoming))
+	kept := make(chan struct{})
+	go func() {
+		n.keepDualWrites(keepCtx, streamed)
+		close(kept)
+	}()
+	stopKeeping := func() {
+		cancel()
+		<-kept
+	}
+	defer stopKeeping()
+	for _, t := range incoming {
+		if err := n.streamRange(ctx, t); err != nil {
+			return nil, err
+		}
+		streamed <- t
+	}
+
+	ring, err := n.tokenRing()
+	if err != nil {
+		return nil, err
+	}
+	n.partitionMu.Lock()
+	next, err := ring.PartitionMap(n.partitionMap)
+	if err != nil {
+		n.partitionMu.Unlock()
+		return nil, err
+	}
+	if next.Epoch != epoch {
+		n.partitionMu.Unlock()
+		return nil, fmt.Errorf("%w: plan is for epoch %d, ring is at %d", ErrStalePlan, epoch, next.Epoch)
+	}
+	n.partitionMap = next
+	n.partitionMu.Unlock()
+	n.resetMerkleTrees(next)
+	log.Printf("[REBALANCE] Applied partition map epoch %d", next.Epoch)
+
+	stopKeeping()
+
+	errs := make([]error, 0)
+	for _, t := range incoming {
+		if err := n.completeHandoff(ctx, t); err != nil {
+			log.Printf("[ERROR] Failed to end dual-writes of %s on %s: %v", t.Range, t.Source, err)
+			errs = append(errs, fmt.Errorf("%w: %s on %s: %w", ErrHandoffNotCompleted, t.Range, t.Source, err))
+		}
+	}
+	return next, errors.Join(errs...)
+}
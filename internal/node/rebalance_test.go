@@ -0,0 +1,45 @@
+package node
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStartRebalanceRunsInBackground(t *testing.T) {
+	n := newTestNode(t, "node-a", "node-b", "node-c")
+	plan, err := n.PlanRebalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.StartRebalance(plan.ToEpoch + 1); !errors.Is(err, ErrStalePlan) {
+		t.Fatalf("starting a rebalance for another epoch returned %v, want ErrStalePlan", err)
+	}
+	if _, ok := n.RebalanceStatus(); ok {
+		t.Fatal("a rejected rebalance left a status")
+	}
+
+	if _, err := n.StartRebalance(plan.ToEpoch); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, ok := n.RebalanceStatus()
+		if !ok {
+			t.Fatal("no status for a started rebalance")
+		}
+		if status.State != RebalanceRunning {
+			if status.State != RebalanceDone || status.PartitionMap == nil || status.PartitionMap.Epoch != plan.ToEpoch {
+				t.Fatalf("rebalance ended with %+v, want done at epoch %d", status, plan.ToEpoch)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rebalance still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n.PartitionMap() == nil {
+		t.Error("no partition map served after the rebalance finished")
+	}
+}
@@ -0,0 +1,398 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"GossamerDB/internal/config"
+	"GossamerDB/internal/hashring"
+	"GossamerDB/internal/quorum"
+	"GossamerDB/internal/security"
+	"GossamerDB/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server exposes a DataNode over HTTP.
+type Server struct {
+	router *gin.Engine
+	srv    *http.Server
+	node   *DataNode
+}
+
+func NewServer(listenAddress string, node *DataNode) *Server {
+	s := &Server{
+		router: gin.Default(),
+		node:   node,
+	}
+	s.setupRoutes()
+	s.srv, _ = security.ConfigureSecureServer(listenAddress, s.router)
+	return s
+}
+
+func (s *Server) setupRoutes() {
+	admin := s.router.Group("/admin")
+	admin.GET("/partition-map", s.handlePartitionMap)
+	admin.GET("/rebalance", s.handlePlanRebalance)
+	admin.POST("/rebalance", s.handleApplyRebalance)
+	admin.GET("/rebalance/status", s.handleRebalanceStatus)
+	admin.GET("/preference-list", s.handlePreferenceList)
+	admin.GET("/hints", s.handleHintStats)
+	admin.GET("/merkle/diff", s.handleMerkleDiff)
+	admin.GET("/anti-entropy", s.handleAntiEntropyStats)
+	admin.GET("/read-repair", s.handleReadRepairStats)
+
+	kv := s.router.Group("/kv")
+	kv.GET("/:key", s.handleGet)
+	kv.PUT("/:key", s.handlePut)
+
+	internal := s.router.Group("/internal")
+	internal.GET("/range", s.handleReadRange)
+	internal.POST("/range/renew", s.handleRenewRange)
+	internal.POST("/range/complete", s.handleCompleteRange)
+	internal.POST("/replicate", s.handleReplicate)
+	internal.GET("/keys/:key", s.handleReadKey)
+	internal.DELETE("/keys/:key", s.handleDeleteKey)
+	internal.GET("/merkle/root", s.handleMerkleRoot)
+	internal.POST("/merkle/hashes", s.handleMerkleHashes)
+	internal.POST("/merkle/leaves", s.handleMerkleLeaves)
+}
+
+// handleGet reads a key at the consistency level named by the consistency
+// query parameter, QUORUM by default.
+func (s *Server) handleGet(c *gin.Context) {
+	level, err := quorum.ParseConsistencyLevel(c.Query("consistency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key := c.Param("key")
+	versions, err := s.node.CoordinateGet(c.Request.Context(), key, level)
+	if err != nil {
+		c.JSON(coordinatorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, RangeEntry{Key: key, Versions: versions})
+}
+
+// handlePut writes the request body as the key's value at the consistency
+// level named by the consistency query parameter, QUORUM by default.
+func (s *Server) handlePut(c *gin.Context) {
+	level, err := quorum.ParseConsistencyLevel(c.Query("consistency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	value, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.node.CoordinatePut(c.Request.Context(), c.Param("key"), value, level); err != nil {
+		c.JSON(coordinatorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// coordinatorStatus maps the errors of coordinated requests to HTTP statuses.
+func coordinatorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrQuorumTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrInsufficientReplicas), errors.Is(err, hashring.ErrNoNodesAvailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *Server) handlePartitionMap(c *gin.Context) {
+	pm := s.node.PartitionMap()
+	if pm == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no partition map applied yet"})
+		return
+	}
+	c.JSON(http.StatusOK, pm)
+}
+
+func (s *Server) handlePlanRebalance(c *gin.Context) {
+	plan, err := s.node.PlanRebalance()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[REBALANCE] Planned %s", plan)
+	c.JSON(http.StatusOK, plan)
+}
+
+// handleApplyRebalance starts applying the plan for the epoch query parameter
+// in the background; its progress is at /admin/rebalance/status.
+func (s *Server) handleApplyRebalance(c *gin.Context) {
+	epoch, err := strconv.ParseUint(c.Query("epoch"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "epoch query parameter is required"})
+		return
+	}
+	status, err := s.node.StartRebalance(epoch)
+	if errors.Is(err, ErrStalePlan) || errors.Is(err, ErrRebalancing) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, status)
+}
+
+func (s *Server) handleRebalanceStatus(c *gin.Context) {
+	status, ok := s.node.RebalanceStatus()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no rebalance started yet"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handlePreferenceList(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key query parameter is required"})
+		return
+	}
+	pl, err := s.node.PreferenceList(key)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	replicas := make([]gin.H, len(pl.Replicas))
+	for i, r := range pl.Replicas {
+		replicas[i] = gin.H{"node": r.Node.GetIdentifier(), "hintFor": r.HintFor}
+	}
+	fallbacks := make([]string, len(pl.Fallbacks))
+	for i, f := range pl.Fallbacks {
+		fallbacks[i] = f.GetIdentifier()
+	}
+	c.JSON(http.StatusOK, gin.H{"replicas": replicas, "fallbacks": fallbacks})
+}
+
+// handleReadRange serves one batch of a token range to a new owner and
+// starts mirroring writes to it.
+func (s *Server) handleReadRange(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dest := c.Query("destination")
+	if dest == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "destination query parameter is required"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(config.ConfigObj.Handoff.BatchSize)))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	// Mirror writes before reading so none fall between the read and the
+	// cutover. If the lease lapsed, writes to keys already sent may have been
+	// missed, so the stream starts over.
+	after := c.Query("after")
+	if s.node.StartDualWrite(r, dest) && after != "" {
+		log.Printf("[HANDOFF] Dual-write lease of %s to %s had lapsed, restarting the stream", r, dest)
+		after = ""
+	}
+	batch, err := s.node.ReadRange(r, dest, after, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// handleReadKey returns this replica's versions of a key, none if it does not
+// have it, for a coordinated read.
+func (s *Server) handleReadKey(c *gin.Context) {
+	key := c.Param("key")
+	versions, err := s.node.Get(key)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, RangeEntry{Key: key, Versions: versions})
+}
+
+// handleRenewRange renews the dual-write lease of a range a new owner
+// finished streaming but has not cut over to yet.
+func (s *Server) handleRenewRange(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dest := c.Query("destination")
+	if dest == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "destination query parameter is required"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lapsed": s.node.StartDualWrite(r, dest)})
+}
+
+func (s *Server) handleCompleteRange(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.node.StopDualWrite(r, c.Query("destination"))
+	c.Status(http.StatusOK)
+}
+
+func (s *Server) handleReplicate(c *gin.Context) {
+	var entry RangeEntry
+	if err := c.ShouldBindJSON(&entry); err != nil || entry.Key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid replicate payload"})
+		return
+	}
+	if err := s.node.ApplyVersions(entry.Key, entry.Versions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// A write for a down owner is kept as a hint to hand back later.
+	if target := c.Query("hintFor"); target != "" {
+		for _, v := range entry.Versions {
+			s.node.StoreHint(target, entry.Key, v)
+		}
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *Server) handleHintStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.node.HintStats())
+}
+
+func (s *Server) handleAntiEntropyStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.node.AntiEntropyStats())
+}
+
+func (s *Server) handleReadRepairStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.node.ReadRepairStats())
+}
+
+func (s *Server) handleDeleteKey(c *gin.Context) {
+	if err := s.node.Delete(c.Param("key")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// handleMerkleDiff compares a range's Merkle tree with a peer's, for
+// operators checking whether two replicas agree.
+func (s *Server) handleMerkleDiff(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	peer := c.Query("peer")
+	if peer == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "peer query parameter is required"})
+		return
+	}
+	diff, err := s.node.DiffWithPeer(c.Request.Context(), peer, r)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func (s *Server) handleMerkleRoot(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	root, err := s.node.MerkleRootInfo(r)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, root)
+}
+
+func (s *Server) handleMerkleHashes(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req merkleHashesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hashes request"})
+		return
+	}
+	hashes, err := s.node.MerkleHashes(r, req.Indices)
+	switch {
+	case errors.Is(err, ErrRangeNotOwned):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, merkleHashesResponse{Hashes: hashes})
+	}
+}
+
+// handleMerkleLeaves serves the key digests of some leaves of a range's tree,
+// for a peer pinning down which keys of those leaves differ.
+func (s *Server) handleMerkleLeaves(c *gin.Context) {
+	r, err := parseTokenRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req merkleHashesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid leaves request"})
+		return
+	}
+	leaves, err := s.node.MerkleLeaves(r, req.Indices)
+	switch {
+	case errors.Is(err, ErrRangeNotOwned):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, merkleLeavesResponse{Leaves: leaves})
+	}
+}
+
+func parseTokenRange(c *gin.Context) (hashring.TokenRange, error) {
+	start, err := strconv.ParseUint(c.Query("start"), 10, 64)
+	if err != nil {
+		return hashring.TokenRange{}, errors.New("start query parameter is required")
+	}
+	end, err := strconv.ParseUint(c.Query("end"), 10, 64)
+	if err != nil {
+		return hashring.TokenRange{}, errors.New("end query parameter is required")
+	}
+	return hashring.TokenRange{Start: start, End: end}, nil
+}
+
+func (s *Server) ListenAndServe() error {
+	log.Printf("[DATA SERVER] Listening on %s\n", s.srv.Addr)
+	return s.srv.ListenAndServe()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
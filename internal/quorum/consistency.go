@@ -0,0 +1,140 @@
+package quorum
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownConsistency = errors.New("unknown consistency level")
+)
+
+// ConsistencyLevel is how many replicas of a key must acknowledge a request.
+type ConsistencyLevel string
+
+const (
+	// ConsistencyOne needs a single replica.
+	ConsistencyOne ConsistencyLevel = "ONE"
+	// ConsistencyQuorum needs readQuorum or writeQuorum replicas, as
+	// configured for the cluster.
+	ConsistencyQuorum ConsistencyLevel = "QUORUM"
+	// ConsistencyAll needs every replica.
+	ConsistencyAll ConsistencyLevel = "ALL"
+	// ConsistencyLocalQuorum needs a majority of the replicas in the
+	// coordinator's region, so multi-region deployments avoid waiting on
+	// remote regions.
+	ConsistencyLocalQuorum ConsistencyLevel = "LOCAL_QUORUM"
+	// ConsistencyEachQuorum needs a majority of the replicas in every region
+	// holding one.
+	ConsistencyEachQuorum ConsistencyLevel = "EACH_QUORUM"
+)
+
+func (l ConsistencyLevel) String() string {
+	return string(l)
+}
+
+// ParseConsistencyLevel parses a level name, case-insensitively. An empty name
+// is QUORUM.
+func ParseConsistencyLevel(s string) (ConsistencyLevel, error) {
+	if s == "" {
+		return ConsistencyQuorum, nil
+	}
+	switch l := ConsistencyLevel(strings.ToUpper(s)); l {
+	case ConsistencyOne, ConsistencyQuorum, ConsistencyAll, ConsistencyLocalQuorum, ConsistencyEachQuorum:
+		return l, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownConsistency, s)
+	}
+}
+
+// Op is the kind of request a consistency level applies to.
+type Op int
+
+const (
+	Read Op = iota
+	Write
+)
+
+func (o Op) String() string {
+	if o == Write {
+		return "write"
+	}
+	return "read"
+}
+
+// Requirement is what a request needs to succeed: Total acks from any
+// replicas, and at least PerRegion[r] of them from replicas in region r.
+type Requirement struct {
+	Total     int
+	PerRegion map[string]int
+}
+
+// Acks returns the fewest acks that can meet the requirement.
+func (r Requirement) Acks() int {
+	sum := 0
+	for _, n := range r.PerRegion {
+		sum += n
+	}
+	return max(r.Total, sum)
+}
+
+// IsMet reports whether acks from the given replicas meet the requirement.
+// regions maps each replica to its region.
+func (r Requirement) IsMet(acked []string, regions map[string]string) bool {
+	if len(acked) < r.Total {
+		return false
+	}
+	if len(r.PerRegion) == 0 {
+		return true
+	}
+	counts := make(map[string]int, len(r.PerRegion))
+	for _, id := range acked {
+		counts[regions[id]]++
+	}
+	for region, need := range r.PerRegion {
+		if counts[region] < need {
+			return false
+		}
+	}
+	return true
+}
+
+// Requirement returns what a request at level needs from the replicas of a
+// key. regions maps each replica to its region and local is the coordinator's
+// region. ONE, QUORUM and ALL map onto the cluster's numbers; the regional
+// levels need a majority of the replicas in the regions they cover, so
+// LOCAL_QUORUM cannot be met when no replica is local.
+func (q *Quorum) Requirement(level ConsistencyLevel, op Op, regions map[string]string, local string) Requirement {
+	switch level {
+	case ConsistencyOne:
+		return Requirement{Total: 1}
+	case ConsistencyAll:
+		return Requirement{Total: q.TotalReplicas()}
+	case ConsistencyLocalQuorum:
+		return Requirement{PerRegion: map[string]int{local: majority(regions, local)}}
+	case ConsistencyEachQuorum:
+		per := make(map[string]int)
+		for _, region := range regions {
+			per[region] = majority(regions, region)
+		}
+		return Requirement{PerRegion: per}
+	default:
+		if op == Write {
+			return Requirement{Total: q.RequiredWriteAcks()}
+		}
+		return Requirement{Total: q.RequiredReadAcks()}
+	}
+}
+
+// majority returns a majority of the replicas in region, and 1 when the
+// region holds none, which no ack can then meet.
+func majority(regions map[string]string, region string) int {
+	n := 0
+	for _, r := range regions {
+		if r == region {
+			n++
+		}
+	}
+	return n/2 + 1
+}
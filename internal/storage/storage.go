@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"errors"
 	"sync"
 
@@ -24,6 +25,20 @@ type Store interface {
 
 	// ListKeys returns all keys stored (useful for building Merkle trees, scans)
 	ListKeys() []string
+
+	// Stats returns the number of keys and bytes held by the store
+	Stats() Stats
+
+	// Checksum returns a digest of the store's contents that does not depend on
+	// the order writes were applied in, which ties state derived from the
+	// store, such as Merkle trees, to exactly these contents
+	Checksum() [sha256.Size]byte
+}
+
+// Stats summarises the contents of a Store.
+type Stats struct {
+	Keys  int   `json:"keys" yaml:"keys"`   // Number of keys stored
+	Bytes int64 `json:"bytes" yaml:"bytes"` // Size of keys and all their versions' values
 }
 
 // memoryStore is a simple in-memory implementation of Store for prototyping.
@@ -33,6 +48,8 @@ type memoryStore struct {
 	resolver conflict.ConflictResolver
 	// maxVersionsPerKey limits number of versions to keep per key.
 	maxVersionsPerKey int
+	// sum is the XOR of the digests of every key and its versions.
+	sum [sha256.Size]byte
 }
 
 // NewMemoryStore returns a new in-memory storage with max version limit.
@@ -61,8 +78,10 @@ func (m *memoryStore) Set(key string, v conflict.VersionedValue) error {
 	defer m.mu.Unlock()
 
 	existing := m.store[key]
+	m.toggle(key, existing)
 	updated := m.mergeVersions(existing, v)
 	m.store[key] = updated
+	m.toggle(key, updated)
 	return nil
 }
 
@@ -70,6 +89,7 @@ func (m *memoryStore) Delete(key string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.toggle(key, m.store[key])
 	delete(m.store, key)
 	return nil
 }
@@ -85,6 +105,44 @@ func (m *memoryStore) ListKeys() []string {
 	return keys
 }
 
+func (m *memoryStore) Stats() Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := Stats{Keys: len(m.store)}
+	for k, versions := range m.store {
+		stats.Bytes += int64(len(k))
+		for _, v := range versions {
+			stats.Bytes += int64(len(v.Value))
+		}
+	}
+	return stats
+}
+
+func (m *memoryStore) Checksum() [sha256.Size]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sum
+}
+
+// toggle adds key and its versions to the checksum, or takes them out if they
+// are already in. A key without versions contributes nothing. Callers must
+// hold m.mu.
+func (m *memoryStore) toggle(key string, versions []conflict.VersionedValue) {
+	if len(versions) == 0 {
+		return
+	}
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(conflict.CanonicalEncoding(versions))
+	var entry [sha256.Size]byte
+	h.Sum(entry[:0])
+	for i := range m.sum {
+		m.sum[i] ^= entry[i]
+	}
+}
+
 // mergeVersions merges a new versioned value into current versions,
 // applies conflict resolution locally (e.g., merge resolver),
 // and respects maxVersionsPerKey limit.
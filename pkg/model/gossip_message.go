@@ -2,9 +2,41 @@ package model
 
 import "time"
 
-type NodeHealthInfo map[string]time.Time
+// NodeHealth is a heartbeat entry for one node. Version is assigned by the
+// node the entry describes and only ever grows, so higher versions win.
+type NodeHealth struct {
+	Heartbeat       time.Time         `json:"heartbeat" yaml:"heartbeat"`                   // Time of the node's last heartbeat
+	Version         uint64            `json:"version" yaml:"version"`                       // Version of this entry
+	Metadata        map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"` // Key/value metadata advertised by the node
+	MetadataVersion uint64            `json:"metadataVersion" yaml:"metadataVersion"`       // Version of Metadata, bumped on every change
+}
+
+type NodeHealthInfo map[string]NodeHealth
+
+// NodeDigest maps nodeID → version of the entry held for that node.
+type NodeDigest map[string]uint64
+
 type GossipMessage struct {
-	SenderID   string         `json:"senderID" yaml:"senderID"`     // Unique ID of the sending node
-	Timestamp  time.Time      `json:"timestamp" yaml:"timestamp"`   // Time message was generated
-	NodeHealth NodeHealthInfo `json:"nodeHealth" yaml:"nodeHealth"` // Map of nodeID → healthy status
+	SenderID    string            `json:"senderID" yaml:"senderID"`                 // Unique ID of the sending node
+	Incarnation uint64            `json:"incarnation" yaml:"incarnation"`           // Incarnation of the sending node, bumped on every restart
+	Region      string            `json:"region" yaml:"region"`                     // Region of the sending node
+	Zone        string            `json:"zone" yaml:"zone"`                         // Availability zone of the sending node
+	Timestamp   time.Time         `json:"timestamp" yaml:"timestamp"`               // Time message was generated
+	NodeHealth  NodeHealthInfo    `json:"nodeHealth" yaml:"nodeHealth"`             // Map of nodeID → healthy status
+	Left        map[string]uint64 `json:"left,omitempty" yaml:"left,omitempty"`     // Map of nodeID → incarnation at which the node left
+	Digest      NodeDigest        `json:"digest,omitempty" yaml:"digest,omitempty"` // Versions the sender holds, for a digest exchange
+}
+
+// DigestReply answers a digest with the entries the sender is missing or holds
+// stale copies of, and the nodes whose entries the receiver wants in return.
+type DigestReply struct {
+	Delta   NodeHealthInfo `json:"delta" yaml:"delta"`     // Entries newer than the sender's digest
+	Request []string       `json:"request" yaml:"request"` // Node IDs the sender holds newer entries for
+}
+
+// LeaveMessage is broadcast by a node that is shutting down gracefully.
+type LeaveMessage struct {
+	NodeID      string    `json:"nodeID" yaml:"nodeID"`           // ID of the node leaving the cluster
+	Incarnation uint64    `json:"incarnation" yaml:"incarnation"` // Incarnation the node is leaving with
+	Timestamp   time.Time `json:"timestamp" yaml:"timestamp"`     // Time the node left
 }
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Well-known keys of the metadata a node advertises through gossip.
+const (
+	MetaHost            = "host"            // Host other nodes reach the node at
+	MetaDataPort        = "dataPort"        // Port of the node's data API
+	MetaCoordinatorPort = "coordinatorPort" // Port of the coordinator service
+	MetaRegion          = "region"          // Region the node runs in
+	MetaZone            = "zone"            // Availability zone the node runs in
+	MetaRack            = "rack"            // Rack the node runs in
+	MetaBuildVersion    = "buildVersion"    // Build the node is running
+	MetaWireFormats     = "wireFormats"     // Comma separated wire formats the node speaks
+	MetaLoad            = "load"            // Current load, in requests per second
+	MetaCapacity        = "capacity"        // Relative capacity of the node
+)
+
+// MemberStatus is the liveness state of a member as seen by the local node.
+type MemberStatus string
+
+const (
+	// MemberAlive indicates a member with a recent heartbeat.
+	MemberAlive MemberStatus = "alive"
+	// MemberSuspect indicates a member that missed heartbeats but is not yet dead.
+	MemberSuspect MemberStatus = "suspect"
+	// MemberDead indicates a member that missed heartbeats for the dead node timeout.
+	MemberDead MemberStatus = "dead"
+	// MemberLeft indicates a member that left the cluster gracefully.
+	MemberLeft MemberStatus = "left"
+)
+
+// Member is a node's view of one cluster member, as learned through gossip.
+type Member struct {
+	ID              string            `json:"id" yaml:"id"`                           // Unique ID of the member
+	Status          MemberStatus      `json:"status" yaml:"status"`                   // Liveness of the member
+	URL             string            `json:"url,omitempty" yaml:"url,omitempty"`     // Gossip URL, known only for direct peers
+	Heartbeat       time.Time         `json:"heartbeat" yaml:"heartbeat"`             // Time of the member's last heartbeat
+	Version         uint64            `json:"version" yaml:"version"`                 // Version of the member's health entry
+	Metadata        map[string]string `json:"metadata" yaml:"metadata"`               // Key/value metadata advertised by the member
+	MetadataVersion uint64            `json:"metadataVersion" yaml:"metadataVersion"` // Version of Metadata
+}
+
+// GetIdentifier returns the member ID, so members can be placed on a hash ring.
+func (m Member) GetIdentifier() string {
+	return m.ID
+}
+
+// GetWeight returns the advertised capacity, used as the member's weight on
+// the hash ring.
+func (m Member) GetWeight() int {
+	return max(m.IntMetadata(MetaCapacity, 1), 1)
+}
+
+// DataURL returns the base URL of the member's data API, or "" if the member
+// did not advertise one.
+func (m Member) DataURL() string {
+	if m.Metadata[MetaHost] == "" || m.Metadata[MetaDataPort] == "" {
+		return ""
+	}
+	return "http://" + net.JoinHostPort(m.Metadata[MetaHost], m.Metadata[MetaDataPort])
+}
+
+// Region returns the region the member advertised.
+func (m Member) Region() string {
+	return m.Metadata[MetaRegion]
+}
+
+// Zone returns the availability zone the member advertised.
+func (m Member) Zone() string {
+	return m.Metadata[MetaZone]
+}
+
+// Rack returns the rack the member advertised.
+func (m Member) Rack() string {
+	return m.Metadata[MetaRack]
+}
+
+// WireFormats returns the wire formats the member advertised.
+func (m Member) WireFormats() []string {
+	if m.Metadata[MetaWireFormats] == "" {
+		return nil
+	}
+	return strings.Split(m.Metadata[MetaWireFormats], ",")
+}
+
+// IntMetadata parses an integer metadata value, returning def if it is absent
+// or malformed.
+func (m Member) IntMetadata(key string, def int) int {
+	v, err := strconv.Atoi(m.Metadata[key])
+	if err != nil {
+		return def
+	}
+	return v
+}